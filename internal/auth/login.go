@@ -0,0 +1,397 @@
+/*
+Copyright © 2020 Andy Lo-A-Foe
+
+Permission is hereby granted, free of charge, to any person obtaining a copy
+of this software and associated documentation files (the "Software"), to deal
+in the Software without restriction, including without limitation the rights
+to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+copies of the Software, and to permit persons to whom the Software is
+furnished to do so, subject to the following conditions:
+
+The above copyright notice and this permission notice shall be included in
+all copies or substantial portions of the Software.
+
+THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+THE SOFTWARE.
+*/
+package auth
+
+import (
+	"crypto/rand"
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"terraform-registry/internal/logging"
+	"terraform-registry/internal/models"
+
+	"github.com/labstack/echo/v4"
+	"golang.org/x/oauth2"
+)
+
+const (
+	// AuthorizationPath is the login.v1 authorization endpoint
+	AuthorizationPath = "/oauth/authorization"
+	// TokenPath is the login.v1 token endpoint
+	TokenPath = "/oauth/token"
+	// CallbackPath receives the redirect from the identity provider
+	CallbackPath = "/oauth/callback"
+
+	loginClientID = "terraform-cli"
+	loginTimeout  = 10 * time.Minute
+)
+
+// loginPorts is the range of local ports terraform may listen on for the redirect
+var loginPorts = []int{10000, 10010}
+
+// Config holds the identity provider and token settings used by terraform login
+type Config struct {
+	IssuerURL    string        `yaml:"issuer_url"`
+	ClientID     string        `yaml:"client_id"`
+	ClientSecret string        `yaml:"client_secret"`
+	RedirectURL  string        `yaml:"redirect_url"`
+	Scopes       []string      `yaml:"scopes"`
+	GroupsClaim  string        `yaml:"groups_claim"`
+	TokenSecret  string        `yaml:"token_secret"`
+	TokenTTL     time.Duration `yaml:"token_ttl"`
+}
+
+// Login implements the terraform login.v1 protocol on top of an OIDC identity provider.
+// Terraform performs an authorization code + PKCE flow against the registry, which in
+// turn authenticates the user with the identity provider and issues a registry token.
+type Login struct {
+	Issuer *Issuer
+
+	oauth       *oauth2.Config
+	idpIssuer   string
+	groupsClaim string
+	now         func() time.Time
+
+	mu             sync.Mutex
+	authorizations map[string]*authorization
+	grants         map[string]*grant
+}
+
+// authorization is a login waiting for the identity provider callback
+type authorization struct {
+	redirectURI string
+	state       string
+	challenge   string
+	verifier    string
+	expires     time.Time
+}
+
+// grant is an authorization code handed to terraform, waiting to be exchanged
+type grant struct {
+	redirectURI string
+	challenge   string
+	subject     string
+	groups      []string
+	expires     time.Time
+}
+
+type providerMetadata struct {
+	Issuer                string `json:"issuer"`
+	AuthorizationEndpoint string `json:"authorization_endpoint"`
+	TokenEndpoint         string `json:"token_endpoint"`
+}
+
+// NewLogin discovers the identity provider and creates a new Login
+func NewLogin(cfg Config) (*Login, error) {
+	if cfg.ClientID == "" || cfg.RedirectURL == "" {
+		return nil, fmt.Errorf("OIDC_CLIENT_ID and OIDC_REDIRECT_URL are required for login")
+	}
+	issuer, err := NewIssuer([]byte(cfg.TokenSecret), cfg.TokenTTL)
+	if err != nil {
+		return nil, err
+	}
+	metadata, err := discover(cfg.IssuerURL)
+	if err != nil {
+		return nil, err
+	}
+	return &Login{
+		Issuer: issuer,
+		oauth: &oauth2.Config{
+			ClientID:     cfg.ClientID,
+			ClientSecret: cfg.ClientSecret,
+			RedirectURL:  cfg.RedirectURL,
+			Scopes:       cfg.Scopes,
+			Endpoint: oauth2.Endpoint{
+				AuthURL:  metadata.AuthorizationEndpoint,
+				TokenURL: metadata.TokenEndpoint,
+			},
+		},
+		idpIssuer:      metadata.Issuer,
+		groupsClaim:    cfg.GroupsClaim,
+		now:            time.Now,
+		authorizations: make(map[string]*authorization),
+		grants:         make(map[string]*grant),
+	}, nil
+}
+
+func discover(issuerURL string) (*providerMetadata, error) {
+	resp, err := http.Get(strings.TrimSuffix(issuerURL, "/") + "/.well-known/openid-configuration")
+	if err != nil {
+		return nil, fmt.Errorf("oidc discovery: %w", err)
+	}
+	defer func() { _ = resp.Body.Close() }()
+
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("oidc discovery: unexpected status %d", resp.StatusCode)
+	}
+	var metadata providerMetadata
+	if err := json.NewDecoder(resp.Body).Decode(&metadata); err != nil {
+		return nil, fmt.Errorf("oidc discovery: %w", err)
+	}
+	if metadata.AuthorizationEndpoint == "" || metadata.TokenEndpoint == "" {
+		return nil, fmt.Errorf("oidc discovery: missing endpoints")
+	}
+	return &metadata, nil
+}
+
+// Service returns the login.v1 service discovery entry
+func (l *Login) Service() *models.LoginV1 {
+	return &models.LoginV1{
+		Client:     loginClientID,
+		GrantTypes: []string{"authz_code"},
+		Authz:      AuthorizationPath,
+		Token:      TokenPath,
+		Ports:      loginPorts,
+	}
+}
+
+// AuthorizationHandler starts a login and redirects to the identity provider
+func (l *Login) AuthorizationHandler() echo.HandlerFunc {
+	return func(c echo.Context) error {
+		if c.QueryParam("client_id") != loginClientID {
+			return loginError(c, "unknown client_id")
+		}
+		if c.QueryParam("response_type") != "code" {
+			return loginError(c, "unsupported response_type")
+		}
+		redirectURI := c.QueryParam("redirect_uri")
+		if !validRedirectURI(redirectURI) {
+			return loginError(c, "invalid redirect_uri")
+		}
+		challenge := c.QueryParam("code_challenge")
+		if challenge == "" || c.QueryParam("code_challenge_method") != "S256" {
+			return loginError(c, "S256 code_challenge is required")
+		}
+
+		state := randomString()
+		verifier := oauth2.GenerateVerifier()
+		l.mu.Lock()
+		l.expire()
+		l.authorizations[state] = &authorization{
+			redirectURI: redirectURI,
+			state:       c.QueryParam("state"),
+			challenge:   challenge,
+			verifier:    verifier,
+			expires:     l.now().Add(loginTimeout),
+		}
+		l.mu.Unlock()
+
+		return c.Redirect(http.StatusFound, l.oauth.AuthCodeURL(state, oauth2.S256ChallengeOption(verifier)))
+	}
+}
+
+// CallbackHandler completes the identity provider login and redirects back to terraform
+func (l *Login) CallbackHandler() echo.HandlerFunc {
+	return func(c echo.Context) error {
+		l.mu.Lock()
+		authz, ok := l.authorizations[c.QueryParam("state")]
+		delete(l.authorizations, c.QueryParam("state"))
+		l.mu.Unlock()
+		if !ok || l.now().After(authz.expires) {
+			return loginError(c, "unknown or expired login")
+		}
+		if idpError := c.QueryParam("error"); idpError != "" {
+			return redirectWith(c, authz, url.Values{"error": {idpError}})
+		}
+
+		token, err := l.oauth.Exchange(c.Request().Context(), c.QueryParam("code"),
+			oauth2.VerifierOption(authz.verifier))
+		if err != nil {
+			logging.FromContext(c.Request().Context()).Error("oidc token exchange", "error", err)
+			return redirectWith(c, authz, url.Values{"error": {"server_error"}})
+		}
+		subject, groups, err := l.identify(token)
+		if err != nil {
+			logging.FromContext(c.Request().Context()).Error("oidc id token", "error", err)
+			return redirectWith(c, authz, url.Values{"error": {"access_denied"}})
+		}
+
+		code := randomString()
+		l.mu.Lock()
+		l.expire()
+		l.grants[code] = &grant{
+			redirectURI: authz.redirectURI,
+			challenge:   authz.challenge,
+			subject:     subject,
+			groups:      groups,
+			expires:     l.now().Add(loginTimeout),
+		}
+		l.mu.Unlock()
+
+		return redirectWith(c, authz, url.Values{"code": {code}})
+	}
+}
+
+// TokenHandler exchanges an authorization code for a registry token
+func (l *Login) TokenHandler() echo.HandlerFunc {
+	return func(c echo.Context) error {
+		if c.FormValue("grant_type") != "authorization_code" {
+			return tokenError(c, "unsupported_grant_type")
+		}
+		code := c.FormValue("code")
+		l.mu.Lock()
+		g, ok := l.grants[code]
+		delete(l.grants, code)
+		l.mu.Unlock()
+		if !ok || l.now().After(g.expires) {
+			return tokenError(c, "invalid_grant")
+		}
+		if c.FormValue("client_id") != loginClientID || c.FormValue("redirect_uri") != g.redirectURI {
+			return tokenError(c, "invalid_grant")
+		}
+		if oauth2.S256ChallengeFromVerifier(c.FormValue("code_verifier")) != g.challenge {
+			return tokenError(c, "invalid_grant")
+		}
+
+		accessToken, err := l.Issuer.Issue(g.subject, g.groups)
+		if err != nil {
+			return err
+		}
+		return c.JSON(http.StatusOK, map[string]interface{}{
+			"access_token": accessToken,
+			"token_type":   "bearer",
+			"expires_in":   int(l.Issuer.TTL().Seconds()),
+		})
+	}
+}
+
+// identify extracts the subject and groups from the ID token. The token was received
+// directly from the token endpoint over TLS, so its signature is not verified again.
+func (l *Login) identify(token *oauth2.Token) (string, []string, error) {
+	rawIDToken, _ := token.Extra("id_token").(string)
+	parts := strings.Split(rawIDToken, ".")
+	if len(parts) != 3 {
+		return "", nil, fmt.Errorf("missing id_token")
+	}
+	payload, err := base64.RawURLEncoding.DecodeString(parts[1])
+	if err != nil {
+		return "", nil, err
+	}
+	claims := make(map[string]interface{})
+	if err := json.Unmarshal(payload, &claims); err != nil {
+		return "", nil, err
+	}
+	if iss, _ := claims["iss"].(string); iss != l.idpIssuer {
+		return "", nil, fmt.Errorf("unexpected issuer %q", iss)
+	}
+	if !audienceContains(claims["aud"], l.oauth.ClientID) {
+		return "", nil, fmt.Errorf("token not issued for %s", l.oauth.ClientID)
+	}
+	if exp, _ := claims["exp"].(float64); l.now().Unix() >= int64(exp) {
+		return "", nil, fmt.Errorf("id_token expired")
+	}
+	subject, _ := claims["sub"].(string)
+	if subject == "" {
+		return "", nil, fmt.Errorf("missing subject")
+	}
+	groups := make([]string, 0)
+	if values, ok := claims[l.groupsClaim].([]interface{}); ok {
+		for _, v := range values {
+			if group, ok := v.(string); ok {
+				groups = append(groups, group)
+			}
+		}
+	}
+	return subject, groups, nil
+}
+
+// expire drops pending logins and grants which timed out. Callers must hold l.mu.
+func (l *Login) expire() {
+	now := l.now()
+	for k, v := range l.authorizations {
+		if now.After(v.expires) {
+			delete(l.authorizations, k)
+		}
+	}
+	for k, v := range l.grants {
+		if now.After(v.expires) {
+			delete(l.grants, k)
+		}
+	}
+}
+
+func audienceContains(aud interface{}, clientID string) bool {
+	switch v := aud.(type) {
+	case string:
+		return v == clientID
+	case []interface{}:
+		for _, a := range v {
+			if a == clientID {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// validRedirectURI only allows the loopback redirects terraform listens on
+func validRedirectURI(redirectURI string) bool {
+	u, err := url.Parse(redirectURI)
+	if err != nil || u.Scheme != "http" {
+		return false
+	}
+	if host := u.Hostname(); host != "localhost" && host != "127.0.0.1" && host != "::1" {
+		return false
+	}
+	port, err := strconv.Atoi(u.Port())
+	if err != nil {
+		return false
+	}
+	return port >= loginPorts[0] && port <= loginPorts[1]
+}
+
+func redirectWith(c echo.Context, authz *authorization, params url.Values) error {
+	u, _ := url.Parse(authz.redirectURI)
+	query := u.Query()
+	for k, v := range params {
+		query[k] = v
+	}
+	query.Set("state", authz.state)
+	u.RawQuery = query.Encode()
+	return c.Redirect(http.StatusFound, u.String())
+}
+
+func loginError(c echo.Context, message string) error {
+	return c.JSON(http.StatusBadRequest, &models.ErrorResponse{
+		Status:  http.StatusBadRequest,
+		Message: message,
+	})
+}
+
+func tokenError(c echo.Context, code string) error {
+	return c.JSON(http.StatusBadRequest, map[string]string{
+		"error": code,
+	})
+}
+
+func randomString() string {
+	b := make([]byte, 32)
+	_, _ = rand.Read(b)
+	return base64.RawURLEncoding.EncodeToString(b)
+}
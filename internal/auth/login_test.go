@@ -0,0 +1,281 @@
+/*
+Copyright © 2020 Andy Lo-A-Foe
+
+Permission is hereby granted, free of charge, to any person obtaining a copy
+of this software and associated documentation files (the "Software"), to deal
+in the Software without restriction, including without limitation the rights
+to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+copies of the Software, and to permit persons to whom the Software is
+furnished to do so, subject to the following conditions:
+
+The above copyright notice and this permission notice shall be included in
+all copies or substantial portions of the Software.
+
+THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+THE SOFTWARE.
+*/
+package auth
+
+import (
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/labstack/echo/v4"
+	"golang.org/x/oauth2"
+)
+
+// mockIdP is a minimal OIDC identity provider issuing unsigned ID tokens
+func mockIdP(t *testing.T, clientID string) *httptest.Server {
+	var server *httptest.Server
+	mux := http.NewServeMux()
+	mux.HandleFunc("/.well-known/openid-configuration", func(w http.ResponseWriter, r *http.Request) {
+		_ = json.NewEncoder(w).Encode(map[string]string{
+			"issuer":                 server.URL,
+			"authorization_endpoint": server.URL + "/authorize",
+			"token_endpoint":         server.URL + "/token",
+		})
+	})
+	mux.HandleFunc("/token", func(w http.ResponseWriter, r *http.Request) {
+		if r.FormValue("code") != "idp-code" || r.FormValue("code_verifier") == "" {
+			w.WriteHeader(http.StatusBadRequest)
+			_, _ = w.Write([]byte(`{"error":"invalid_grant"}`))
+			return
+		}
+		claims, _ := json.Marshal(map[string]interface{}{
+			"iss":    server.URL,
+			"aud":    clientID,
+			"sub":    "alice",
+			"exp":    time.Now().Add(time.Hour).Unix(),
+			"groups": []string{"platform"},
+		})
+		idToken := "e30." + base64.RawURLEncoding.EncodeToString(claims) + "."
+		w.Header().Set("Content-Type", "application/json")
+		_ = json.NewEncoder(w).Encode(map[string]interface{}{
+			"access_token": "idp-access-token",
+			"token_type":   "Bearer",
+			"id_token":     idToken,
+		})
+	})
+	server = httptest.NewServer(mux)
+	t.Cleanup(server.Close)
+	return server
+}
+
+func newTestLogin(t *testing.T) *Login {
+	idp := mockIdP(t, "registry")
+	login, err := NewLogin(Config{
+		IssuerURL:   idp.URL,
+		ClientID:    "registry",
+		RedirectURL: "https://registry.example.com/oauth/callback",
+		Scopes:      []string{"openid"},
+		GroupsClaim: "groups",
+		TokenSecret: string(testSecret),
+		TokenTTL:    time.Hour,
+	})
+	if err != nil {
+		t.Fatalf("NewLogin() error = %v", err)
+	}
+	return login
+}
+
+func serve(e *echo.Echo, method, target string, form url.Values) *httptest.ResponseRecorder {
+	var req *http.Request
+	if form != nil {
+		req = httptest.NewRequest(method, target, strings.NewReader(form.Encode()))
+		req.Header.Set(echo.HeaderContentType, echo.MIMEApplicationForm)
+	} else {
+		req = httptest.NewRequest(method, target, nil)
+	}
+	rec := httptest.NewRecorder()
+	e.ServeHTTP(rec, req)
+	return rec
+}
+
+func TestNewLoginDiscoveryFailure(t *testing.T) {
+	server := httptest.NewServer(http.NotFoundHandler())
+	defer server.Close()
+
+	_, err := NewLogin(Config{
+		IssuerURL:   server.URL,
+		ClientID:    "registry",
+		RedirectURL: "https://registry.example.com/oauth/callback",
+		TokenSecret: string(testSecret),
+		TokenTTL:    time.Hour,
+	})
+	if err == nil {
+		t.Error("NewLogin() expected error for failed discovery, got nil")
+	}
+}
+
+func TestLoginFlow(t *testing.T) {
+	login := newTestLogin(t)
+	e := echo.New()
+	e.GET(AuthorizationPath, login.AuthorizationHandler())
+	e.GET(CallbackPath, login.CallbackHandler())
+	e.POST(TokenPath, login.TokenHandler())
+
+	verifier := oauth2.GenerateVerifier()
+	redirectURI := "http://localhost:10000/login"
+	authz := url.Values{
+		"client_id":             {"terraform-cli"},
+		"response_type":         {"code"},
+		"redirect_uri":          {redirectURI},
+		"state":                 {"terraform-state"},
+		"code_challenge":        {oauth2.S256ChallengeFromVerifier(verifier)},
+		"code_challenge_method": {"S256"},
+	}
+
+	// Terraform opens the authorization endpoint in the browser
+	rec := serve(e, http.MethodGet, AuthorizationPath+"?"+authz.Encode(), nil)
+	if rec.Code != http.StatusFound {
+		t.Fatalf("authorization: expected status %d, got %d", http.StatusFound, rec.Code)
+	}
+	idpRedirect, _ := url.Parse(rec.Header().Get(echo.HeaderLocation))
+	if idpRedirect.Query().Get("code_challenge") == "" {
+		t.Error("authorization: identity provider redirect lacks PKCE challenge")
+	}
+
+	// The identity provider redirects back to the registry
+	callback := url.Values{"code": {"idp-code"}, "state": {idpRedirect.Query().Get("state")}}
+	rec = serve(e, http.MethodGet, CallbackPath+"?"+callback.Encode(), nil)
+	if rec.Code != http.StatusFound {
+		t.Fatalf("callback: expected status %d, got %d", http.StatusFound, rec.Code)
+	}
+	tfRedirect, _ := url.Parse(rec.Header().Get(echo.HeaderLocation))
+	if !strings.HasPrefix(tfRedirect.String(), redirectURI) {
+		t.Fatalf("callback: redirected to %s, want %s", tfRedirect, redirectURI)
+	}
+	if tfRedirect.Query().Get("state") != "terraform-state" {
+		t.Errorf("callback: state = %s, want terraform-state", tfRedirect.Query().Get("state"))
+	}
+	code := tfRedirect.Query().Get("code")
+
+	// A wrong verifier must not yield a token
+	rec = serve(e, http.MethodPost, TokenPath, url.Values{
+		"grant_type":    {"authorization_code"},
+		"client_id":     {"terraform-cli"},
+		"redirect_uri":  {redirectURI},
+		"code":          {code},
+		"code_verifier": {oauth2.GenerateVerifier()},
+	})
+	if rec.Code != http.StatusBadRequest {
+		t.Errorf("token: expected status %d for wrong verifier, got %d", http.StatusBadRequest, rec.Code)
+	}
+
+	// Codes are single use, so run the flow's final step with a fresh grant
+	login.grants[code] = &grant{
+		redirectURI: redirectURI,
+		challenge:   oauth2.S256ChallengeFromVerifier(verifier),
+		subject:     "alice",
+		groups:      []string{"platform"},
+		expires:     time.Now().Add(time.Minute),
+	}
+	rec = serve(e, http.MethodPost, TokenPath, url.Values{
+		"grant_type":    {"authorization_code"},
+		"client_id":     {"terraform-cli"},
+		"redirect_uri":  {redirectURI},
+		"code":          {code},
+		"code_verifier": {verifier},
+	})
+	if rec.Code != http.StatusOK {
+		t.Fatalf("token: expected status %d, got %d: %s", http.StatusOK, rec.Code, rec.Body.String())
+	}
+	var response struct {
+		AccessToken string `json:"access_token"`
+	}
+	_ = json.Unmarshal(rec.Body.Bytes(), &response)
+	identity, err := login.Issuer.Verify(response.AccessToken)
+	if err != nil {
+		t.Fatalf("token: issued token does not verify: %v", err)
+	}
+	if identity.Subject != "alice" {
+		t.Errorf("token: subject = %s, want alice", identity.Subject)
+	}
+}
+
+func TestCallbackExchangesWithIdP(t *testing.T) {
+	login := newTestLogin(t)
+	e := echo.New()
+	e.GET(CallbackPath, login.CallbackHandler())
+
+	login.authorizations["idp-state"] = &authorization{
+		redirectURI: "http://127.0.0.1:10005/login",
+		state:       "terraform-state",
+		challenge:   "challenge",
+		verifier:    oauth2.GenerateVerifier(),
+		expires:     time.Now().Add(time.Minute),
+	}
+	rec := serve(e, http.MethodGet, CallbackPath+"?code=idp-code&state=idp-state", nil)
+	location, _ := url.Parse(rec.Header().Get(echo.HeaderLocation))
+	code := location.Query().Get("code")
+	if code == "" {
+		t.Fatalf("callback: no code in redirect %s", location)
+	}
+	g := login.grants[code]
+	if g == nil || g.subject != "alice" || len(g.groups) != 1 || g.groups[0] != "platform" {
+		t.Errorf("callback: grant = %+v, want subject alice in group platform", g)
+	}
+}
+
+func TestAuthorizationHandlerValidation(t *testing.T) {
+	login := newTestLogin(t)
+	e := echo.New()
+	e.GET(AuthorizationPath, login.AuthorizationHandler())
+
+	valid := func() url.Values {
+		return url.Values{
+			"client_id":             {"terraform-cli"},
+			"response_type":         {"code"},
+			"redirect_uri":          {"http://localhost:10000/login"},
+			"state":                 {"state"},
+			"code_challenge":        {"challenge"},
+			"code_challenge_method": {"S256"},
+		}
+	}
+
+	tests := []struct {
+		name   string
+		modify func(v url.Values)
+	}{
+		{name: "Unknown client", modify: func(v url.Values) { v.Set("client_id", "other") }},
+		{name: "Implicit flow", modify: func(v url.Values) { v.Set("response_type", "token") }},
+		{name: "Remote redirect", modify: func(v url.Values) { v.Set("redirect_uri", "http://evil.example.com:10000/login") }},
+		{name: "Port out of range", modify: func(v url.Values) { v.Set("redirect_uri", "http://localhost:8080/login") }},
+		{name: "Plain challenge", modify: func(v url.Values) { v.Set("code_challenge_method", "plain") }},
+		{name: "Missing challenge", modify: func(v url.Values) { v.Del("code_challenge") }},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			params := valid()
+			tt.modify(params)
+			rec := serve(e, http.MethodGet, fmt.Sprintf("%s?%s", AuthorizationPath, params.Encode()), nil)
+			if rec.Code != http.StatusBadRequest {
+				t.Errorf("Expected status code %d, got %d", http.StatusBadRequest, rec.Code)
+			}
+		})
+	}
+}
+
+func TestService(t *testing.T) {
+	login := newTestLogin(t)
+	service := login.Service()
+	if service.Client != "terraform-cli" {
+		t.Errorf("Service() client = %s, want terraform-cli", service.Client)
+	}
+	if service.Authz != AuthorizationPath || service.Token != TokenPath {
+		t.Errorf("Service() endpoints = %s %s", service.Authz, service.Token)
+	}
+}
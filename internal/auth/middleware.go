@@ -0,0 +1,64 @@
+/*
+Copyright © 2020 Andy Lo-A-Foe
+
+Permission is hereby granted, free of charge, to any person obtaining a copy
+of this software and associated documentation files (the "Software"), to deal
+in the Software without restriction, including without limitation the rights
+to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+copies of the Software, and to permit persons to whom the Software is
+furnished to do so, subject to the following conditions:
+
+The above copyright notice and this permission notice shall be included in
+all copies or substantial portions of the Software.
+
+THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+THE SOFTWARE.
+*/
+package auth
+
+import (
+	"net/http"
+	"strings"
+
+	"terraform-registry/internal/models"
+
+	"github.com/labstack/echo/v4"
+)
+
+const identityKey = "identity"
+
+// Middleware rejects requests which do not carry a valid registry token
+func Middleware(issuer *Issuer) echo.MiddlewareFunc {
+	return func(next echo.HandlerFunc) echo.HandlerFunc {
+		return func(c echo.Context) error {
+			header := c.Request().Header.Get(echo.HeaderAuthorization)
+			scheme, token, found := strings.Cut(header, " ")
+			if !found || !strings.EqualFold(scheme, "bearer") {
+				return c.JSON(http.StatusUnauthorized, &models.ErrorResponse{
+					Status:  http.StatusUnauthorized,
+					Message: "missing bearer token",
+				})
+			}
+			identity, err := issuer.Verify(strings.TrimSpace(token))
+			if err != nil {
+				return c.JSON(http.StatusUnauthorized, &models.ErrorResponse{
+					Status:  http.StatusUnauthorized,
+					Message: err.Error(),
+				})
+			}
+			c.Set(identityKey, identity)
+			return next(c)
+		}
+	}
+}
+
+// GetIdentity returns the identity of the authenticated caller, or nil
+func GetIdentity(c echo.Context) *Identity {
+	identity, _ := c.Get(identityKey).(*Identity)
+	return identity
+}
@@ -0,0 +1,75 @@
+/*
+Copyright © 2020 Andy Lo-A-Foe
+
+Permission is hereby granted, free of charge, to any person obtaining a copy
+of this software and associated documentation files (the "Software"), to deal
+in the Software without restriction, including without limitation the rights
+to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+copies of the Software, and to permit persons to whom the Software is
+furnished to do so, subject to the following conditions:
+
+The above copyright notice and this permission notice shall be included in
+all copies or substantial portions of the Software.
+
+THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+THE SOFTWARE.
+*/
+package auth
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/labstack/echo/v4"
+)
+
+func TestMiddleware(t *testing.T) {
+	issuer, _ := NewIssuer(testSecret, time.Hour)
+	token, _ := issuer.Issue("alice", []string{"platform"})
+
+	tests := []struct {
+		name       string
+		header     string
+		wantStatus int
+	}{
+		{name: "Missing header", header: "", wantStatus: http.StatusUnauthorized},
+		{name: "Basic auth", header: "Basic YWxpY2U6c2VjcmV0", wantStatus: http.StatusUnauthorized},
+		{name: "Invalid token", header: "Bearer tfr.invalid.token", wantStatus: http.StatusUnauthorized},
+		{name: "Valid token", header: "Bearer " + token, wantStatus: http.StatusOK},
+		{name: "Lowercase scheme", header: "bearer " + token, wantStatus: http.StatusOK},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			e := echo.New()
+			req := httptest.NewRequest(http.MethodGet, "/v1/providers/philips/hsdp/versions", nil)
+			if tt.header != "" {
+				req.Header.Set(echo.HeaderAuthorization, tt.header)
+			}
+			rec := httptest.NewRecorder()
+			c := e.NewContext(req, rec)
+
+			var identity *Identity
+			handler := Middleware(issuer)(func(c echo.Context) error {
+				identity = GetIdentity(c)
+				return c.NoContent(http.StatusOK)
+			})
+			if err := handler(c); err != nil {
+				t.Fatalf("Middleware() error = %v", err)
+			}
+			if rec.Code != tt.wantStatus {
+				t.Errorf("Expected status code %d, got %d", tt.wantStatus, rec.Code)
+			}
+			if tt.wantStatus == http.StatusOK && (identity == nil || identity.Subject != "alice") {
+				t.Errorf("GetIdentity() = %v, want subject alice", identity)
+			}
+		})
+	}
+}
@@ -0,0 +1,111 @@
+/*
+Copyright © 2020 Andy Lo-A-Foe
+
+Permission is hereby granted, free of charge, to any person obtaining a copy
+of this software and associated documentation files (the "Software"), to deal
+in the Software without restriction, including without limitation the rights
+to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+copies of the Software, and to permit persons to whom the Software is
+furnished to do so, subject to the following conditions:
+
+The above copyright notice and this permission notice shall be included in
+all copies or substantial portions of the Software.
+
+THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+THE SOFTWARE.
+*/
+package auth
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"strings"
+	"time"
+)
+
+const tokenPrefix = "tfr"
+
+// Identity describes the caller a registry token was issued to
+type Identity struct {
+	Subject string   `json:"sub"`
+	Groups  []string `json:"groups,omitempty"`
+	Expires int64    `json:"exp"`
+}
+
+// Issuer issues and verifies HMAC signed registry tokens
+type Issuer struct {
+	secret []byte
+	ttl    time.Duration
+	now    func() time.Time
+}
+
+// NewIssuer creates a new Issuer signing tokens with secret, valid for ttl
+func NewIssuer(secret []byte, ttl time.Duration) (*Issuer, error) {
+	if len(secret) < 32 {
+		return nil, fmt.Errorf("token secret must be at least 32 bytes")
+	}
+	if ttl <= 0 {
+		return nil, fmt.Errorf("token ttl must be positive")
+	}
+	return &Issuer{
+		secret: secret,
+		ttl:    ttl,
+		now:    time.Now,
+	}, nil
+}
+
+// TTL returns the lifetime of issued tokens
+func (i *Issuer) TTL() time.Duration {
+	return i.ttl
+}
+
+// Issue returns a signed token for the given subject and groups
+func (i *Issuer) Issue(subject string, groups []string) (string, error) {
+	payload, err := json.Marshal(&Identity{
+		Subject: subject,
+		Groups:  groups,
+		Expires: i.now().Add(i.ttl).Unix(),
+	})
+	if err != nil {
+		return "", err
+	}
+	encoded := base64.RawURLEncoding.EncodeToString(payload)
+	return tokenPrefix + "." + encoded + "." + i.sign(encoded), nil
+}
+
+// Verify checks the signature and expiry of token and returns its identity
+func (i *Issuer) Verify(token string) (*Identity, error) {
+	parts := strings.Split(token, ".")
+	if len(parts) != 3 || parts[0] != tokenPrefix {
+		return nil, fmt.Errorf("malformed token")
+	}
+	if !hmac.Equal([]byte(parts[2]), []byte(i.sign(parts[1]))) {
+		return nil, fmt.Errorf("invalid token signature")
+	}
+	payload, err := base64.RawURLEncoding.DecodeString(parts[1])
+	if err != nil {
+		return nil, fmt.Errorf("malformed token: %w", err)
+	}
+	var identity Identity
+	if err := json.Unmarshal(payload, &identity); err != nil {
+		return nil, fmt.Errorf("malformed token: %w", err)
+	}
+	if i.now().Unix() >= identity.Expires {
+		return nil, fmt.Errorf("token expired")
+	}
+	return &identity, nil
+}
+
+func (i *Issuer) sign(payload string) string {
+	mac := hmac.New(sha256.New, i.secret)
+	_, _ = mac.Write([]byte(payload))
+	return base64.RawURLEncoding.EncodeToString(mac.Sum(nil))
+}
@@ -0,0 +1,108 @@
+/*
+Copyright © 2020 Andy Lo-A-Foe
+
+Permission is hereby granted, free of charge, to any person obtaining a copy
+of this software and associated documentation files (the "Software"), to deal
+in the Software without restriction, including without limitation the rights
+to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+copies of the Software, and to permit persons to whom the Software is
+furnished to do so, subject to the following conditions:
+
+The above copyright notice and this permission notice shall be included in
+all copies or substantial portions of the Software.
+
+THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+THE SOFTWARE.
+*/
+package auth
+
+import (
+	"strings"
+	"testing"
+	"time"
+)
+
+var testSecret = []byte("0123456789abcdef0123456789abcdef")
+
+func TestNewIssuer(t *testing.T) {
+	tests := []struct {
+		name    string
+		secret  []byte
+		ttl     time.Duration
+		wantErr bool
+	}{
+		{name: "Valid", secret: testSecret, ttl: time.Hour},
+		{name: "Short secret", secret: []byte("short"), ttl: time.Hour, wantErr: true},
+		{name: "Zero ttl", secret: testSecret, ttl: 0, wantErr: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := NewIssuer(tt.secret, tt.ttl)
+			if (err != nil) != tt.wantErr {
+				t.Errorf("NewIssuer() error = %v, wantErr %v", err, tt.wantErr)
+			}
+		})
+	}
+}
+
+func TestIssueAndVerify(t *testing.T) {
+	issuer, err := NewIssuer(testSecret, time.Hour)
+	if err != nil {
+		t.Fatalf("NewIssuer() error = %v", err)
+	}
+
+	token, err := issuer.Issue("alice", []string{"platform"})
+	if err != nil {
+		t.Fatalf("Issue() error = %v", err)
+	}
+	if !strings.HasPrefix(token, "tfr.") {
+		t.Errorf("Issue() token %s does not have tfr prefix", token)
+	}
+
+	identity, err := issuer.Verify(token)
+	if err != nil {
+		t.Fatalf("Verify() error = %v", err)
+	}
+	if identity.Subject != "alice" {
+		t.Errorf("Verify() subject = %s, want alice", identity.Subject)
+	}
+	if len(identity.Groups) != 1 || identity.Groups[0] != "platform" {
+		t.Errorf("Verify() groups = %v, want [platform]", identity.Groups)
+	}
+}
+
+func TestVerifyRejects(t *testing.T) {
+	issuer, _ := NewIssuer(testSecret, time.Hour)
+	other, _ := NewIssuer([]byte("fedcba9876543210fedcba9876543210"), time.Hour)
+	expired, _ := NewIssuer(testSecret, time.Hour)
+	expired.now = func() time.Time { return time.Now().Add(-2 * time.Hour) }
+
+	otherToken, _ := other.Issue("alice", nil)
+	expiredToken, _ := expired.Issue("alice", nil)
+	validToken, _ := issuer.Issue("alice", nil)
+
+	tests := []struct {
+		name  string
+		token string
+	}{
+		{name: "Empty", token: ""},
+		{name: "Wrong prefix", token: "abc" + strings.TrimPrefix(validToken, "tfr")},
+		{name: "Foreign signature", token: otherToken},
+		{name: "Expired", token: expiredToken},
+		{name: "Tampered payload", token: "tfr.eyJzdWIiOiJtYWxsb3J5In0." + strings.Split(validToken, ".")[2]},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if _, err := issuer.Verify(tt.token); err == nil {
+				t.Error("Verify() expected error, got nil")
+			}
+		})
+	}
+}
@@ -0,0 +1,113 @@
+/*
+Copyright © 2020 Andy Lo-A-Foe
+
+Permission is hereby granted, free of charge, to any person obtaining a copy
+of this software and associated documentation files (the "Software"), to deal
+in the Software without restriction, including without limitation the rights
+to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+copies of the Software, and to permit persons to whom the Software is
+furnished to do so, subject to the following conditions:
+
+The above copyright notice and this permission notice shall be included in
+all copies or substantial portions of the Software.
+
+THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+THE SOFTWARE.
+*/
+package cache
+
+import (
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+type entry[V any] struct {
+	value   V
+	expires time.Time
+}
+
+// Cache is an in-memory key value cache whose entries expire after a fixed TTL.
+// A nil Cache, or one with a TTL of zero, caches nothing.
+type Cache[V any] struct {
+	ttl time.Duration
+	now func() time.Time
+
+	mu    sync.Mutex
+	items map[string]entry[V]
+
+	hits   atomic.Uint64
+	misses atomic.Uint64
+}
+
+// New creates a new Cache keeping entries for ttl
+func New[V any](ttl time.Duration) *Cache[V] {
+	return &Cache[V]{
+		ttl:   ttl,
+		now:   time.Now,
+		items: make(map[string]entry[V]),
+	}
+}
+
+// Get returns the cached value for key, if present and not expired
+func (c *Cache[V]) Get(key string) (V, bool) {
+	var zero V
+	if c == nil {
+		return zero, false
+	}
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	e, ok := c.items[key]
+	if !ok {
+		c.misses.Add(1)
+		return zero, false
+	}
+	if c.now().After(e.expires) {
+		delete(c.items, key)
+		c.misses.Add(1)
+		return zero, false
+	}
+	c.hits.Add(1)
+	return e.value, true
+}
+
+// Stats returns the number of lookups which hit and missed the cache
+func (c *Cache[V]) Stats() (hits, misses uint64) {
+	if c == nil {
+		return 0, 0
+	}
+	return c.hits.Load(), c.misses.Load()
+}
+
+// Set stores value under key
+func (c *Cache[V]) Set(key string, value V) {
+	if c == nil || c.ttl <= 0 {
+		return
+	}
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	now := c.now()
+	for k, e := range c.items {
+		if now.After(e.expires) {
+			delete(c.items, k)
+		}
+	}
+	c.items[key] = entry[V]{value: value, expires: now.Add(c.ttl)}
+}
+
+// Len returns the number of entries, including expired ones not yet evicted
+func (c *Cache[V]) Len() int {
+	if c == nil {
+		return 0
+	}
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return len(c.items)
+}
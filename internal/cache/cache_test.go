@@ -0,0 +1,85 @@
+/*
+Copyright © 2020 Andy Lo-A-Foe
+
+Permission is hereby granted, free of charge, to any person obtaining a copy
+of this software and associated documentation files (the "Software"), to deal
+in the Software without restriction, including without limitation the rights
+to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+copies of the Software, and to permit persons to whom the Software is
+furnished to do so, subject to the following conditions:
+
+The above copyright notice and this permission notice shall be included in
+all copies or substantial portions of the Software.
+
+THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+THE SOFTWARE.
+*/
+package cache
+
+import (
+	"testing"
+	"time"
+)
+
+func TestCache(t *testing.T) {
+	now := time.Now()
+	c := New[string](time.Minute)
+	c.now = func() time.Time { return now }
+
+	if _, ok := c.Get("key"); ok {
+		t.Error("Get() on empty cache returned a value")
+	}
+
+	c.Set("key", "value")
+	if v, ok := c.Get("key"); !ok || v != "value" {
+		t.Errorf("Get() = %v, %v, want value, true", v, ok)
+	}
+
+	now = now.Add(2 * time.Minute)
+	if _, ok := c.Get("key"); ok {
+		t.Error("Get() returned an expired value")
+	}
+	if c.Len() != 0 {
+		t.Errorf("Len() = %d, want 0 after expiry", c.Len())
+	}
+	if hits, misses := c.Stats(); hits != 1 || misses != 2 {
+		t.Errorf("Stats() = %d, %d, want 1, 2", hits, misses)
+	}
+}
+
+func TestCacheDisabled(t *testing.T) {
+	tests := []struct {
+		name  string
+		cache *Cache[int]
+	}{
+		{name: "Nil cache", cache: nil},
+		{name: "Zero TTL", cache: New[int](0)},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			tt.cache.Set("key", 1)
+			if _, ok := tt.cache.Get("key"); ok {
+				t.Error("Get() returned a value from a disabled cache")
+			}
+		})
+	}
+}
+
+func TestCacheEvictsOnSet(t *testing.T) {
+	now := time.Now()
+	c := New[int](time.Minute)
+	c.now = func() time.Time { return now }
+
+	c.Set("old", 1)
+	now = now.Add(2 * time.Minute)
+	c.Set("new", 2)
+	if c.Len() != 1 {
+		t.Errorf("Len() = %d, want 1", c.Len())
+	}
+}
@@ -0,0 +1,155 @@
+/*
+Copyright © 2020 Andy Lo-A-Foe
+
+Permission is hereby granted, free of charge, to any person obtaining a copy
+of this software and associated documentation files (the "Software"), to deal
+in the Software without restriction, including without limitation the rights
+to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+copies of the Software, and to permit persons to whom the Software is
+furnished to do so, subject to the following conditions:
+
+The above copyright notice and this permission notice shall be included in
+all copies or substantial portions of the Software.
+
+THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+THE SOFTWARE.
+*/
+package certs
+
+import (
+	"crypto/tls"
+	"errors"
+	"fmt"
+	"os"
+	"sync"
+	"time"
+
+	"golang.org/x/crypto/acme"
+	"golang.org/x/crypto/acme/autocert"
+)
+
+// defaultCacheDir is where ACME certificates are kept unless a cache directory is configured
+const defaultCacheDir = "acme-cache"
+
+// FileReloader serves a certificate and key from files, loading them again once either changes
+// so renewed certificates are picked up without a restart
+type FileReloader struct {
+	certFile string
+	keyFile  string
+
+	mu       sync.Mutex
+	cert     *tls.Certificate
+	modified time.Time
+}
+
+// NewFileReloader creates a FileReloader, failing when the files do not hold a valid key pair
+func NewFileReloader(certFile, keyFile string) (*FileReloader, error) {
+	r := &FileReloader{certFile: certFile, keyFile: keyFile}
+	if _, err := r.GetCertificate(nil); err != nil {
+		return nil, err
+	}
+	return r, nil
+}
+
+// GetCertificate returns the current certificate, for use in tls.Config. A key pair which
+// fails to load keeps the previous certificate in use.
+func (r *FileReloader) GetCertificate(*tls.ClientHelloInfo) (*tls.Certificate, error) {
+	modified, err := r.lastModified()
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if err != nil || modified.Equal(r.modified) {
+		if r.cert == nil {
+			return nil, fmt.Errorf("loading certificate: %w", err)
+		}
+		return r.cert, nil
+	}
+	cert, err := tls.LoadX509KeyPair(r.certFile, r.keyFile)
+	if err != nil {
+		if r.cert == nil {
+			return nil, fmt.Errorf("loading certificate: %w", err)
+		}
+		return r.cert, nil
+	}
+	r.cert = &cert
+	r.modified = modified
+	return r.cert, nil
+}
+
+// lastModified returns the latest modification time of the certificate and key files
+func (r *FileReloader) lastModified() (time.Time, error) {
+	var latest time.Time
+	for _, name := range []string{r.certFile, r.keyFile} {
+		info, err := os.Stat(name)
+		if err != nil {
+			return time.Time{}, err
+		}
+		if info.ModTime().After(latest) {
+			latest = info.ModTime()
+		}
+	}
+	return latest, nil
+}
+
+// Options configures how the registry obtains its certificate
+type Options struct {
+	CertFile string `yaml:"cert_file"`
+	KeyFile  string `yaml:"key_file"`
+	ACME     ACME   `yaml:"acme"`
+}
+
+// ACME configures certificates obtained through ACME
+type ACME struct {
+	Domains      []string `yaml:"domains"`
+	Email        string   `yaml:"email"`
+	CacheDir     string   `yaml:"cache_dir"`
+	DirectoryURL string   `yaml:"directory_url"`
+}
+
+// NewConfig creates the TLS configuration of the registry: certificate files, or certificates
+// obtained through ACME. It returns nil when neither is configured, so the registry serves plain HTTP.
+func NewConfig(o Options) (*tls.Config, error) {
+	switch {
+	case len(o.ACME.Domains) > 0 && (o.CertFile != "" || o.KeyFile != ""):
+		return nil, errors.New("ACME cannot be combined with a certificate and key file")
+	case len(o.ACME.Domains) > 0:
+		return acmeConfig(o.ACME), nil
+	case o.CertFile == "" && o.KeyFile == "":
+		return nil, nil
+	case o.CertFile == "" || o.KeyFile == "":
+		return nil, errors.New("a certificate file and key file must be configured together")
+	}
+	reloader, err := NewFileReloader(o.CertFile, o.KeyFile)
+	if err != nil {
+		return nil, err
+	}
+	return &tls.Config{
+		MinVersion:     tls.VersionTLS12,
+		GetCertificate: reloader.GetCertificate,
+	}, nil
+}
+
+// acmeConfig obtains and renews certificates for the configured domains using the TLS-ALPN-01
+// challenge, so the registry needs to be reachable on port 443
+func acmeConfig(o ACME) *tls.Config {
+	cacheDir := o.CacheDir
+	if cacheDir == "" {
+		cacheDir = defaultCacheDir
+	}
+	manager := &autocert.Manager{
+		Prompt:     autocert.AcceptTOS,
+		HostPolicy: autocert.HostWhitelist(o.Domains...),
+		Cache:      autocert.DirCache(cacheDir),
+		Email:      o.Email,
+	}
+	if o.DirectoryURL != "" {
+		manager.Client = &acme.Client{DirectoryURL: o.DirectoryURL}
+	}
+	config := manager.TLSConfig()
+	config.MinVersion = tls.VersionTLS12
+	return config
+}
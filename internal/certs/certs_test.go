@@ -0,0 +1,135 @@
+/*
+Copyright © 2020 Andy Lo-A-Foe
+
+Permission is hereby granted, free of charge, to any person obtaining a copy
+of this software and associated documentation files (the "Software"), to deal
+in the Software without restriction, including without limitation the rights
+to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+copies of the Software, and to permit persons to whom the Software is
+furnished to do so, subject to the following conditions:
+
+The above copyright notice and this permission notice shall be included in
+all copies or substantial portions of the Software.
+
+THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+THE SOFTWARE.
+*/
+package certs
+
+import (
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/pem"
+	"math/big"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+// writeKeyPair writes a self-signed certificate for name, modified at the given time
+func writeKeyPair(t *testing.T, dir, name string, modified time.Time) (string, string) {
+	t.Helper()
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatalf("GenerateKey() error = %v", err)
+	}
+	template := &x509.Certificate{
+		SerialNumber: big.NewInt(1),
+		Subject:      pkix.Name{CommonName: name},
+		DNSNames:     []string{name},
+		NotBefore:    time.Now().Add(-time.Hour),
+		NotAfter:     time.Now().Add(time.Hour),
+	}
+	der, err := x509.CreateCertificate(rand.Reader, template, template, &key.PublicKey, key)
+	if err != nil {
+		t.Fatalf("CreateCertificate() error = %v", err)
+	}
+	keyDER, _ := x509.MarshalECPrivateKey(key)
+	certFile, keyFile := filepath.Join(dir, "tls.crt"), filepath.Join(dir, "tls.key")
+	_ = os.WriteFile(certFile, pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: der}), 0o600)
+	_ = os.WriteFile(keyFile, pem.EncodeToMemory(&pem.Block{Type: "EC PRIVATE KEY", Bytes: keyDER}), 0o600)
+	for _, file := range []string{certFile, keyFile} {
+		if err := os.Chtimes(file, modified, modified); err != nil {
+			t.Fatalf("Chtimes() error = %v", err)
+		}
+	}
+	return certFile, keyFile
+}
+
+func commonName(t *testing.T, r *FileReloader) string {
+	t.Helper()
+	cert, err := r.GetCertificate(nil)
+	if err != nil {
+		t.Fatalf("GetCertificate() error = %v", err)
+	}
+	leaf, err := x509.ParseCertificate(cert.Certificate[0])
+	if err != nil {
+		t.Fatalf("ParseCertificate() error = %v", err)
+	}
+	return leaf.Subject.CommonName
+}
+
+func TestFileReloader(t *testing.T) {
+	dir := t.TempDir()
+	now := time.Now()
+	certFile, keyFile := writeKeyPair(t, dir, "old.example.com", now.Add(-time.Minute))
+
+	r, err := NewFileReloader(certFile, keyFile)
+	if err != nil {
+		t.Fatalf("NewFileReloader() error = %v", err)
+	}
+	if name := commonName(t, r); name != "old.example.com" {
+		t.Errorf("GetCertificate() = %s, want old.example.com", name)
+	}
+
+	writeKeyPair(t, dir, "new.example.com", now)
+	if name := commonName(t, r); name != "new.example.com" {
+		t.Errorf("GetCertificate() after renewal = %s, want new.example.com", name)
+	}
+
+	_ = os.WriteFile(keyFile, []byte("garbage"), 0o600)
+	if name := commonName(t, r); name != "new.example.com" {
+		t.Errorf("GetCertificate() with a broken key = %s, want new.example.com", name)
+	}
+}
+
+func TestNewConfig(t *testing.T) {
+	dir := t.TempDir()
+	certFile, keyFile := writeKeyPair(t, dir, "registry.example.com", time.Now())
+	acme := ACME{Domains: []string{"registry.example.com"}, CacheDir: filepath.Join(dir, "acme")}
+
+	tests := []struct {
+		name    string
+		options Options
+		wantTLS bool
+		wantErr bool
+	}{
+		{name: "Plain HTTP"},
+		{name: "Certificate files", options: Options{CertFile: certFile, KeyFile: keyFile}, wantTLS: true},
+		{name: "Missing key file", options: Options{CertFile: certFile}, wantErr: true},
+		{name: "Unreadable files", options: Options{CertFile: certFile, KeyFile: filepath.Join(dir, "missing.key")}, wantErr: true},
+		{name: "ACME", options: Options{ACME: acme}, wantTLS: true},
+		{name: "ACME with files", options: Options{CertFile: certFile, KeyFile: keyFile, ACME: acme}, wantErr: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			config, err := NewConfig(tt.options)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("NewConfig() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if (config != nil) != tt.wantTLS {
+				t.Errorf("NewConfig() config = %v, wantTLS %v", config, tt.wantTLS)
+			}
+		})
+	}
+}
@@ -25,26 +25,112 @@ import (
 	"context"
 	"fmt"
 	"net/http"
-	"os"
+	"strings"
+	"sync/atomic"
+	"time"
+
+	"terraform-registry/internal/cache"
 
 	"github.com/google/go-github/v32/github"
 	"github.com/labstack/echo/v4"
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/trace"
 	"golang.org/x/oauth2"
 )
 
+var tracer = otel.Tracer("terraform-registry/internal/client")
+
 // Client wraps the GitHub client and provides methods for interacting with GitHub repositories
 type Client struct {
 	Github        *github.Client
 	Authenticated bool
 	HTTP          *http.Client
+
+	releases   *cache.Cache[[]*github.RepositoryRelease]
+	namespaces atomic.Pointer[namespaceMap]
+}
+
+// namespaceMap maps registry namespaces to GitHub owners and back, keyed in lower case
+type namespaceMap struct {
+	owners     map[string]string
+	namespaces map[string]string
+}
+
+// providerPrefix is the repository name prefix of terraform providers
+const providerPrefix = "terraform-provider-"
+
+// Option configures a Client
+type Option func(*options)
+
+type options struct {
+	transport     http.RoundTripper
+	token         string
+	enterpriseURL string
+	uploadURL     string
+	releaseTTL    time.Duration
+	namespaces    map[string]string
+}
+
+// WithTransport makes the Client send its GitHub API requests through transport
+func WithTransport(transport http.RoundTripper) Option {
+	return func(o *options) {
+		o.transport = transport
+	}
+}
+
+// WithToken authenticates the GitHub API requests with a personal access token
+func WithToken(token string) Option {
+	return func(o *options) {
+		o.token = token
+	}
+}
+
+// WithEnterprise talks to a GitHub Enterprise Server API at baseURL. Uploads go to
+// uploadURL, which defaults to baseURL when empty.
+func WithEnterprise(baseURL, uploadURL string) Option {
+	return func(o *options) {
+		o.enterpriseURL = baseURL
+		o.uploadURL = uploadURL
+	}
+}
+
+// WithReleaseCacheTTL caches the releases of each repository for ttl
+func WithReleaseCacheTTL(ttl time.Duration) Option {
+	return func(o *options) {
+		o.releaseTTL = ttl
+	}
+}
+
+// WithNamespaces maps registry namespaces to the GitHub owners hosting their providers.
+// Namespaces which are not mapped are GitHub owners themselves.
+func WithNamespaces(namespaces map[string]string) Option {
+	return func(o *options) {
+		o.namespaces = namespaces
+	}
 }
 
 // NewClient creates a new Client instance with optional GitHub authentication
-func NewClient() (*Client, error) {
+func NewClient(opts ...Option) (*Client, error) {
 	client := &Client{}
+	var o options
+	for _, opt := range opts {
+		opt(&o)
+	}
+	if o.transport != nil {
+		client.HTTP = &http.Client{Transport: o.transport}
+	}
+	if o.releaseTTL > 0 {
+		client.releases = cache.New[[]*github.RepositoryRelease](o.releaseTTL)
+	}
+	client.SetNamespaces(o.namespaces)
 
-	if token, ok := os.LookupEnv("GITHUB_TOKEN"); ok {
+	if token := o.token; token != "" {
 		ctx := context.Background()
+		if client.HTTP != nil {
+			ctx = context.WithValue(ctx, oauth2.HTTPClient, client.HTTP)
+		}
 		ts := oauth2.StaticTokenSource(
 			&oauth2.Token{AccessToken: token},
 		)
@@ -53,10 +139,10 @@ func NewClient() (*Client, error) {
 		client.Authenticated = true
 	}
 
-	if serverURL := os.Getenv("GITHUB_ENTERPRISE_URL"); serverURL != "" {
+	if serverURL := o.enterpriseURL; serverURL != "" {
 		uploadURL := serverURL
 
-		if url := os.Getenv("GITHUB_ENTERPRISE_UPLOADS_URL"); url != "" {
+		if url := o.uploadURL; url != "" {
 			uploadURL = url
 		}
 
@@ -75,12 +161,16 @@ func NewClient() (*Client, error) {
 
 // GetURL retrieves the download URL for a GitHub release asset
 func (client *Client) GetURL(c echo.Context, asset *github.ReleaseAsset) (string, error) {
-	if client.Authenticated {
-		namespace := c.Get("namespace").(string)
-		provider := c.Get("provider").(string)
+	if !client.Authenticated {
+		return *asset.BrowserDownloadURL, nil
+	}
+	return client.AssetURL(c.Request().Context(), c.Get("namespace").(string), c.Get("provider").(string), asset)
+}
 
-		_, url, err := client.Github.Repositories.DownloadReleaseAsset(context.Background(),
-			namespace, provider, *asset.ID, nil)
+// AssetURL retrieves the download URL for a release asset of the namespace/repo repository
+func (client *Client) AssetURL(ctx context.Context, namespace, repo string, asset *github.ReleaseAsset) (string, error) {
+	if client.Authenticated {
+		_, url, err := client.Github.Repositories.DownloadReleaseAsset(ctx, client.Owner(namespace), repo, *asset.ID, nil)
 		if err != nil {
 			return "", err
 		}
@@ -90,3 +180,162 @@ func (client *Client) GetURL(c echo.Context, asset *github.ReleaseAsset) (string
 
 	return *asset.BrowserDownloadURL, nil
 }
+
+// SetNamespaces replaces the mapping of registry namespaces to GitHub owners, see WithNamespaces
+func (client *Client) SetNamespaces(namespaces map[string]string) {
+	m := &namespaceMap{
+		owners:     make(map[string]string, len(namespaces)),
+		namespaces: make(map[string]string, len(namespaces)),
+	}
+	for namespace, owner := range namespaces {
+		m.owners[strings.ToLower(namespace)] = owner
+		m.namespaces[strings.ToLower(owner)] = namespace
+	}
+	client.namespaces.Store(m)
+}
+
+// Owner returns the GitHub owner hosting the providers of a registry namespace
+func (client *Client) Owner(namespace string) string {
+	if m := client.namespaces.Load(); m != nil {
+		if owner, ok := m.owners[strings.ToLower(namespace)]; ok {
+			return owner
+		}
+	}
+	return namespace
+}
+
+// Namespace returns the registry namespace of the providers hosted by a GitHub owner
+func (client *Client) Namespace(owner string) string {
+	if m := client.namespaces.Load(); m != nil {
+		if namespace, ok := m.namespaces[strings.ToLower(owner)]; ok {
+			return namespace
+		}
+	}
+	return owner
+}
+
+// ListReleases lists all releases of the namespace/repo repository, served from the release
+// cache when a release cache TTL is set. The response is that of the last page, nil for
+// cached releases.
+func (client *Client) ListReleases(ctx context.Context, namespace, repo string) ([]*github.RepositoryRelease, *github.Response, error) {
+	owner := client.Owner(namespace)
+	key := owner + "/" + repo
+	ctx, span := tracer.Start(ctx, "github.ListReleases", trace.WithAttributes(attribute.String("repository", key)))
+	defer span.End()
+
+	if releases, ok := client.releases.Get(key); ok {
+		span.SetAttributes(attribute.Bool("cache.hit", true))
+		return releases, nil, nil
+	}
+	var releases []*github.RepositoryRelease
+	opt := &github.ListOptions{PerPage: 100}
+	for {
+		page, resp, err := client.Github.Repositories.ListReleases(ctx, owner, repo, opt)
+		if err != nil {
+			span.SetStatus(codes.Error, err.Error())
+			return nil, resp, err
+		}
+		releases = append(releases, page...)
+		if resp.NextPage == 0 {
+			client.releases.Set(key, releases)
+			return releases, resp, nil
+		}
+		opt.Page = resp.NextPage
+	}
+}
+
+// CacheStats returns the hits and misses of the release cache
+func (client *Client) CacheStats() (hits, misses uint64) {
+	return client.releases.Stats()
+}
+
+// CachedReleases returns the number of repositories whose releases are cached
+func (client *Client) CachedReleases() int {
+	return client.releases.Len()
+}
+
+// RateLimit returns the core GitHub API rate limit of the client's credentials. Checking it
+// does not count against the limit, it fails when the credentials are invalid.
+func (client *Client) RateLimit(ctx context.Context) (*github.Rate, error) {
+	limits, _, err := client.Github.RateLimits(ctx)
+	if err != nil {
+		return nil, err
+	}
+	return limits.GetCore(), nil
+}
+
+// ListProviderRepos lists the terraform-provider-* repositories of a GitHub organization
+// or user
+func (client *Client) ListProviderRepos(ctx context.Context, owner string) ([]*github.Repository, error) {
+	var repos []*github.Repository
+	opt := &github.RepositoryListByOrgOptions{ListOptions: github.ListOptions{PerPage: 100}}
+	for {
+		page, resp, err := client.Github.Repositories.ListByOrg(ctx, owner, opt)
+		if err != nil {
+			if resp != nil && resp.StatusCode == http.StatusNotFound {
+				return client.listUserProviderRepos(ctx, owner)
+			}
+			return nil, err
+		}
+		repos = append(repos, providerRepos(page)...)
+		if resp.NextPage == 0 {
+			return repos, nil
+		}
+		opt.Page = resp.NextPage
+	}
+}
+
+func (client *Client) listUserProviderRepos(ctx context.Context, user string) ([]*github.Repository, error) {
+	var repos []*github.Repository
+	opt := &github.RepositoryListOptions{ListOptions: github.ListOptions{PerPage: 100}}
+	for {
+		page, resp, err := client.Github.Repositories.List(ctx, user, opt)
+		if err != nil {
+			return nil, err
+		}
+		repos = append(repos, providerRepos(page)...)
+		if resp.NextPage == 0 {
+			return repos, nil
+		}
+		opt.Page = resp.NextPage
+	}
+}
+
+// SearchProviderRepos searches the terraform-provider-* repositories of owners
+// matching query. Qualifiers in query selecting other owners or repositories are dropped.
+func (client *Client) SearchProviderRepos(ctx context.Context, query string, owners []string) ([]*github.Repository, error) {
+	var terms []string
+	for _, term := range strings.Fields(query) {
+		if !ownerQualifier(term) {
+			terms = append(terms, term)
+		}
+	}
+	terms = append(terms, providerPrefix+" in:name")
+	for _, owner := range owners {
+		terms = append(terms, "user:"+owner)
+	}
+	result, _, err := client.Github.Search.Repositories(ctx, strings.Join(terms, " "),
+		&github.SearchOptions{ListOptions: github.ListOptions{PerPage: 100}})
+	if err != nil {
+		return nil, err
+	}
+	return providerRepos(result.Repositories), nil
+}
+
+// ownerQualifier reports whether a search term is a user:, org: or repo: qualifier,
+// which GitHub would combine with the owners being searched
+func ownerQualifier(term string) bool {
+	qualifier, _, ok := strings.Cut(strings.ToLower(strings.TrimPrefix(term, "-")), ":")
+	return ok && (qualifier == "user" || qualifier == "org" || qualifier == "repo")
+}
+
+// providerRepos keeps the repositories which are terraform providers
+func providerRepos(repos []*github.Repository) []*github.Repository {
+	providers := make([]*github.Repository, 0, len(repos))
+	for _, r := range repos {
+		if strings.HasPrefix(r.GetName(), providerPrefix) {
+			providers = append(providers, r)
+		}
+	}
+	return providers
+}
@@ -22,71 +22,46 @@ THE SOFTWARE.
 package client
 
 import (
+	"context"
 	"net/http"
 	"net/http/httptest"
-	"os"
+	"net/url"
 	"testing"
+	"time"
+
+	"terraform-registry/internal/cache"
 
 	"github.com/google/go-github/v32/github"
 	"github.com/labstack/echo/v4"
 )
 
 func TestNewClient(t *testing.T) {
-	// Save original env vars
-	origToken := os.Getenv("GITHUB_TOKEN")
-	origServerURL := os.Getenv("GITHUB_ENTERPRISE_URL")
-	origUploadURL := os.Getenv("GITHUB_ENTERPRISE_UPLOADS_URL")
-
-	// Restore after test
-	defer func() {
-		_ = os.Setenv("GITHUB_TOKEN", origToken)
-		_ = os.Setenv("GITHUB_ENTERPRISE_URL", origServerURL)
-		_ = os.Setenv("GITHUB_ENTERPRISE_UPLOADS_URL", origUploadURL)
-	}()
-
 	tests := []struct {
 		name              string
-		setupEnv          func()
+		opts              []Option
 		wantAuthenticated bool
 		wantErr           bool
 	}{
 		{
-			name: "Without authentication",
-			setupEnv: func() {
-				_ = os.Unsetenv("GITHUB_TOKEN")
-				_ = os.Unsetenv("GITHUB_ENTERPRISE_URL")
-				_ = os.Unsetenv("GITHUB_ENTERPRISE_UPLOADS_URL")
-			},
+			name:              "Without authentication",
 			wantAuthenticated: false,
 			wantErr:           false,
 		},
 		{
-			name: "With authentication token",
-			setupEnv: func() {
-				_ = os.Setenv("GITHUB_TOKEN", "test-token")
-				_ = os.Unsetenv("GITHUB_ENTERPRISE_URL")
-				_ = os.Unsetenv("GITHUB_ENTERPRISE_UPLOADS_URL")
-			},
+			name:              "With authentication token",
+			opts:              []Option{WithToken("test-token")},
 			wantAuthenticated: true,
 			wantErr:           false,
 		},
 		{
-			name: "With enterprise URL",
-			setupEnv: func() {
-				_ = os.Unsetenv("GITHUB_TOKEN")
-				_ = os.Setenv("GITHUB_ENTERPRISE_URL", "https://github.example.com/api/v3/")
-				_ = os.Unsetenv("GITHUB_ENTERPRISE_UPLOADS_URL")
-			},
+			name:              "With enterprise URL",
+			opts:              []Option{WithEnterprise("https://github.example.com/api/v3/", "")},
 			wantAuthenticated: false,
 			wantErr:           false,
 		},
 		{
-			name: "With enterprise URL and token",
-			setupEnv: func() {
-				_ = os.Setenv("GITHUB_TOKEN", "test-token")
-				_ = os.Setenv("GITHUB_ENTERPRISE_URL", "https://github.example.com/api/v3/")
-				_ = os.Unsetenv("GITHUB_ENTERPRISE_UPLOADS_URL")
-			},
+			name:              "With enterprise URL and token",
+			opts:              []Option{WithToken("test-token"), WithEnterprise("https://github.example.com/api/v3/", "")},
 			wantAuthenticated: true,
 			wantErr:           false,
 		},
@@ -94,9 +69,7 @@ func TestNewClient(t *testing.T) {
 
 	for _, tt := range tests {
 		t.Run(tt.name, func(t *testing.T) {
-			tt.setupEnv()
-
-			client, err := NewClient()
+			client, err := NewClient(tt.opts...)
 			if (err != nil) != tt.wantErr {
 				t.Errorf("NewClient() error = %v, wantErr %v", err, tt.wantErr)
 				return
@@ -117,6 +90,37 @@ func TestNewClient(t *testing.T) {
 	}
 }
 
+func TestNamespaces(t *testing.T) {
+	client, err := NewClient(WithNamespaces(map[string]string{"acme": "acme-corp-terraform"}))
+	if err != nil {
+		t.Fatalf("NewClient() error = %v", err)
+	}
+	tests := []struct {
+		namespace string
+		owner     string
+	}{
+		{"acme", "acme-corp-terraform"},
+		{"ACME", "acme-corp-terraform"},
+		{"philips", "philips"},
+	}
+	for _, tt := range tests {
+		if owner := client.Owner(tt.namespace); owner != tt.owner {
+			t.Errorf("Owner(%s) = %s, want %s", tt.namespace, owner, tt.owner)
+		}
+	}
+	if namespace := client.Namespace("Acme-Corp-Terraform"); namespace != "acme" {
+		t.Errorf("Namespace() = %s, want acme", namespace)
+	}
+
+	client.SetNamespaces(map[string]string{"philips": "philips-software"})
+	if owner := client.Owner("acme"); owner != "acme" {
+		t.Errorf("Owner() after SetNamespaces = %s, want acme", owner)
+	}
+	if owner := client.Owner("philips"); owner != "philips-software" {
+		t.Errorf("Owner() after SetNamespaces = %s, want philips-software", owner)
+	}
+}
+
 func TestGetURL(t *testing.T) {
 	tests := []struct {
 		name          string
@@ -189,3 +193,159 @@ func TestClientFields(t *testing.T) {
 		t.Error("Client.HTTP should not be nil")
 	}
 }
+
+// newTestClient creates a Client for a fake GitHub API served by mux
+func newTestClient(t *testing.T, mux *http.ServeMux) *Client {
+	t.Helper()
+	server := httptest.NewServer(mux)
+	t.Cleanup(server.Close)
+	gh := github.NewClient(nil)
+	gh.BaseURL, _ = url.Parse(server.URL + "/")
+	return &Client{Github: gh}
+}
+
+func TestListReleases(t *testing.T) {
+	requests := 0
+	mux := http.NewServeMux()
+	mux.HandleFunc("/repos/philips/terraform-provider-hsdp/releases", func(w http.ResponseWriter, r *http.Request) {
+		requests++
+		_, _ = w.Write([]byte(`[{"tag_name":"v1.0.0"}]`))
+	})
+
+	tests := []struct {
+		name         string
+		ttl          time.Duration
+		wantRequests int
+	}{
+		{name: "Without cache", ttl: 0, wantRequests: 2},
+		{name: "With cache", ttl: time.Minute, wantRequests: 1},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			requests = 0
+			client := newTestClient(t, mux)
+			client.releases = cache.New[[]*github.RepositoryRelease](tt.ttl)
+			for i := 0; i < 2; i++ {
+				releases, _, err := client.ListReleases(context.Background(), "philips", "terraform-provider-hsdp")
+				if err != nil {
+					t.Fatalf("ListReleases() error = %v", err)
+				}
+				if len(releases) != 1 || releases[0].GetTagName() != "v1.0.0" {
+					t.Errorf("ListReleases() = %v, want v1.0.0", releases)
+				}
+			}
+			if requests != tt.wantRequests {
+				t.Errorf("ListReleases() made %d requests, want %d", requests, tt.wantRequests)
+			}
+		})
+	}
+}
+
+func TestListReleasesPages(t *testing.T) {
+	mux := http.NewServeMux()
+	mux.HandleFunc("/repos/philips/terraform-provider-hsdp/releases", func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Query().Get("page") == "2" {
+			_, _ = w.Write([]byte(`[{"tag_name":"v1.0.0"}]`))
+			return
+		}
+		w.Header().Set("Link", `<`+r.URL.Path+`?page=2>; rel="next"`)
+		_, _ = w.Write([]byte(`[{"tag_name":"v1.1.0"}]`))
+	})
+	client := newTestClient(t, mux)
+	releases, _, err := client.ListReleases(context.Background(), "philips", "terraform-provider-hsdp")
+	if err != nil {
+		t.Fatalf("ListReleases() error = %v", err)
+	}
+	if len(releases) != 2 || releases[1].GetTagName() != "v1.0.0" {
+		t.Errorf("ListReleases() = %v, want both pages", releases)
+	}
+}
+
+func TestListProviderRepos(t *testing.T) {
+	repos := `[{"name":"terraform-provider-hsdp"},{"name":"website"}]`
+	mux := http.NewServeMux()
+	mux.HandleFunc("/orgs/philips/repos", func(w http.ResponseWriter, r *http.Request) {
+		_, _ = w.Write([]byte(repos))
+	})
+	mux.HandleFunc("/orgs/loafoe/repos", func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusNotFound)
+	})
+	mux.HandleFunc("/users/loafoe/repos", func(w http.ResponseWriter, r *http.Request) {
+		_, _ = w.Write([]byte(repos))
+	})
+	client := newTestClient(t, mux)
+
+	tests := []struct {
+		name    string
+		owner   string
+		want    int
+		wantErr bool
+	}{
+		{name: "Organization", owner: "philips", want: 1},
+		{name: "User", owner: "loafoe", want: 1},
+		{name: "Unknown", owner: "nobody", wantErr: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := client.ListProviderRepos(context.Background(), tt.owner)
+			if (err != nil) != tt.wantErr {
+				t.Errorf("ListProviderRepos() error = %v, wantErr %v", err, tt.wantErr)
+				return
+			}
+			if len(got) != tt.want {
+				t.Errorf("ListProviderRepos() = %d repos, want %d", len(got), tt.want)
+			}
+		})
+	}
+}
+
+type countingTransport struct {
+	requests int
+}
+
+func (c *countingTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	c.requests++
+	return http.DefaultTransport.RoundTrip(req)
+}
+
+func TestWithTransport(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		_, _ = w.Write([]byte(`[]`))
+	}))
+	defer server.Close()
+
+	for _, token := range []string{"", "test-token"} {
+		t.Run("token "+token, func(t *testing.T) {
+			transport := &countingTransport{}
+			client, err := NewClient(WithTransport(transport), WithToken(token), WithEnterprise(server.URL+"/", ""))
+			if err != nil {
+				t.Fatalf("NewClient() error = %v", err)
+			}
+			if _, _, err := client.ListReleases(context.Background(), "philips", "terraform-provider-hsdp"); err != nil {
+				t.Fatalf("ListReleases() error = %v", err)
+			}
+			if transport.requests != 1 {
+				t.Errorf("transport saw %d requests, want 1", transport.requests)
+			}
+		})
+	}
+}
+
+func TestOwnerQualifier(t *testing.T) {
+	tests := []struct {
+		term string
+		want bool
+	}{
+		{"hsdp", false},
+		{"in:name", false},
+		{"user:someone", true},
+		{"ORG:acme", true},
+		{"repo:acme/terraform-provider-x", true},
+		{"-user:philips", true},
+	}
+	for _, tt := range tests {
+		if got := ownerQualifier(tt.term); got != tt.want {
+			t.Errorf("ownerQualifier(%q) = %v, want %v", tt.term, got, tt.want)
+		}
+	}
+}
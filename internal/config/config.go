@@ -0,0 +1,301 @@
+/*
+Copyright © 2020 Andy Lo-A-Foe
+
+Permission is hereby granted, free of charge, to any person obtaining a copy
+of this software and associated documentation files (the "Software"), to deal
+in the Software without restriction, including without limitation the rights
+to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+copies of the Software, and to permit persons to whom the Software is
+furnished to do so, subject to the following conditions:
+
+The above copyright notice and this permission notice shall be included in
+all copies or substantial portions of the Software.
+
+THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+THE SOFTWARE.
+*/
+package config
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+	"time"
+
+	"terraform-registry/internal/auth"
+	"terraform-registry/internal/certs"
+	"terraform-registry/internal/download"
+	"terraform-registry/internal/logging"
+	"terraform-registry/internal/policy"
+	"terraform-registry/internal/verify"
+
+	"gopkg.in/yaml.v3"
+)
+
+// Config is the configuration of the registry. It is read from a YAML file, after which
+// the environment variables the registry has always supported override its settings.
+type Config struct {
+	Server Server `yaml:"server"`
+	GitHub GitHub `yaml:"github"`
+	// Namespaces maps registry namespaces to the GitHub owners hosting their providers
+	Namespaces map[string]string `yaml:"namespaces"`
+	Cache      Cache             `yaml:"cache"`
+	Upstream   Upstream          `yaml:"upstream"`
+	Mirror     Mirror            `yaml:"mirror"`
+	Verify     Verify            `yaml:"verify"`
+	Policy     Policy            `yaml:"policy"`
+	OIDC       auth.Config       `yaml:"oidc"`
+	Log        logging.Options   `yaml:"log"`
+}
+
+// Server configures the listener and request handling
+type Server struct {
+	Address string `yaml:"address"`
+	// MetricsAddress serves the metrics on a separate listener, without authentication
+	MetricsAddress  string        `yaml:"metrics_address"`
+	RequestTimeout  time.Duration `yaml:"request_timeout"`
+	UpstreamTimeout time.Duration `yaml:"upstream_timeout"`
+	ShutdownTimeout time.Duration `yaml:"shutdown_timeout"`
+	TLS             certs.Options `yaml:"tls"`
+}
+
+// GitHub configures access to the GitHub API
+type GitHub struct {
+	Token                string   `yaml:"token"`
+	EnterpriseURL        string   `yaml:"enterprise_url"`
+	EnterpriseUploadsURL string   `yaml:"enterprise_uploads_url"`
+	Owners               []string `yaml:"owners"`
+}
+
+// Cache configures the GitHub release cache
+type Cache struct {
+	ReleasesTTL time.Duration `yaml:"releases_ttl"`
+}
+
+// Upstream configures the registry unknown providers are forwarded to
+type Upstream struct {
+	Registry string        `yaml:"registry"`
+	CacheTTL time.Duration `yaml:"cache_ttl"`
+}
+
+// Mirror configures the artifact mirror
+type Mirror struct {
+	Dir       string `yaml:"dir"`
+	URLSecret string `yaml:"url_secret"`
+}
+
+// Verify configures checksum verification of provider zips
+type Verify struct {
+	Mode    string `yaml:"mode"`
+	Workers int    `yaml:"workers"`
+}
+
+// Policy configures access policies, either from a separate file or inline
+type Policy struct {
+	File          string `yaml:"file"`
+	policy.Policy `yaml:",inline"`
+}
+
+// Engine creates the policy engine, nil when no policy is configured
+func (p Policy) Engine() (*policy.Engine, error) {
+	if p.File != "" {
+		return policy.LoadFile(p.File)
+	}
+	if p.Default == "" && len(p.Rules) == 0 {
+		return nil, nil
+	}
+	return policy.NewEngine(p.Policy)
+}
+
+// Default returns the configuration used when nothing is configured
+func Default() *Config {
+	return &Config{
+		Server: Server{
+			Address:         ":8080",
+			RequestTimeout:  time.Minute,
+			UpstreamTimeout: download.DefaultTimeout,
+			ShutdownTimeout: 30 * time.Second,
+		},
+		Verify: Verify{Workers: 2},
+		OIDC: auth.Config{
+			Scopes:      []string{"openid", "profile", "email"},
+			GroupsClaim: "groups",
+			TokenTTL:    24 * time.Hour,
+		},
+		Log: logging.Options{Format: logging.JSON, Level: "info"},
+	}
+}
+
+// Load reads the configuration file, when filename is not empty, applies the environment
+// overrides and validates the result
+func Load(filename string) (*Config, error) {
+	cfg := Default()
+	if filename != "" {
+		data, err := os.ReadFile(filename)
+		if err != nil {
+			return nil, err
+		}
+		decoder := yaml.NewDecoder(bytes.NewReader(data))
+		decoder.KnownFields(true)
+		if err := decoder.Decode(cfg); err != nil && !errors.Is(err, io.EOF) {
+			return nil, fmt.Errorf("parsing config %s: %w", filename, err)
+		}
+	}
+	if err := cfg.applyEnv(); err != nil {
+		return nil, err
+	}
+	if err := cfg.Validate(); err != nil {
+		return nil, err
+	}
+	return cfg, nil
+}
+
+// applyEnv overrides settings with the environment variables which are set
+func (cfg *Config) applyEnv() error {
+	if port := os.Getenv("PORT"); port != "" {
+		cfg.Server.Address = ":" + port
+	}
+	for name, value := range map[string]*string{
+		"METRICS_ADDRESS":               &cfg.Server.MetricsAddress,
+		"GITHUB_TOKEN":                  &cfg.GitHub.Token,
+		"GITHUB_ENTERPRISE_URL":         &cfg.GitHub.EnterpriseURL,
+		"GITHUB_ENTERPRISE_UPLOADS_URL": &cfg.GitHub.EnterpriseUploadsURL,
+		"TLS_CERT_FILE":                 &cfg.Server.TLS.CertFile,
+		"TLS_KEY_FILE":                  &cfg.Server.TLS.KeyFile,
+		"ACME_EMAIL":                    &cfg.Server.TLS.ACME.Email,
+		"ACME_CACHE_DIR":                &cfg.Server.TLS.ACME.CacheDir,
+		"ACME_DIRECTORY_URL":            &cfg.Server.TLS.ACME.DirectoryURL,
+		"UPSTREAM_REGISTRY":             &cfg.Upstream.Registry,
+		"MIRROR_DIR":                    &cfg.Mirror.Dir,
+		"MIRROR_URL_SECRET":             &cfg.Mirror.URLSecret,
+		"VERIFY_CHECKSUMS":              &cfg.Verify.Mode,
+		"POLICY_FILE":                   &cfg.Policy.File,
+		"OIDC_ISSUER_URL":               &cfg.OIDC.IssuerURL,
+		"OIDC_CLIENT_ID":                &cfg.OIDC.ClientID,
+		"OIDC_CLIENT_SECRET":            &cfg.OIDC.ClientSecret,
+		"OIDC_REDIRECT_URL":             &cfg.OIDC.RedirectURL,
+		"OIDC_GROUPS_CLAIM":             &cfg.OIDC.GroupsClaim,
+		"TOKEN_SECRET":                  &cfg.OIDC.TokenSecret,
+		"LOG_FORMAT":                    &cfg.Log.Format,
+		"LOG_LEVEL":                     &cfg.Log.Level,
+	} {
+		if v := os.Getenv(name); v != "" {
+			*value = v
+		}
+	}
+	for name, value := range map[string]*[]string{
+		"GITHUB_OWNERS": &cfg.GitHub.Owners,
+		"ACME_DOMAINS":  &cfg.Server.TLS.ACME.Domains,
+		"OIDC_SCOPES":   &cfg.OIDC.Scopes,
+	} {
+		if v := os.Getenv(name); v != "" {
+			*value = splitList(v)
+		}
+	}
+	for name, value := range map[string]*time.Duration{
+		"REQUEST_TIMEOUT":    &cfg.Server.RequestTimeout,
+		"UPSTREAM_TIMEOUT":   &cfg.Server.UpstreamTimeout,
+		"SHUTDOWN_TIMEOUT":   &cfg.Server.ShutdownTimeout,
+		"RELEASE_CACHE_TTL":  &cfg.Cache.ReleasesTTL,
+		"UPSTREAM_CACHE_TTL": &cfg.Upstream.CacheTTL,
+		"TOKEN_TTL":          &cfg.OIDC.TokenTTL,
+	} {
+		if v := os.Getenv(name); v != "" {
+			d, err := time.ParseDuration(v)
+			if err != nil {
+				return fmt.Errorf("invalid %s: %w", name, err)
+			}
+			*value = d
+		}
+	}
+	if v := os.Getenv("NAMESPACES"); v != "" {
+		cfg.Namespaces = make(map[string]string)
+		for _, mapping := range splitList(v) {
+			namespace, owner, _ := strings.Cut(mapping, "=")
+			cfg.Namespaces[namespace] = owner
+		}
+	}
+	return nil
+}
+
+// Validate checks the configuration, reporting all problems at once
+func (cfg *Config) Validate() error {
+	var errs []error
+	if cfg.Server.Address == "" {
+		errs = append(errs, errors.New("server.address is required"))
+	}
+	for name, d := range map[string]time.Duration{
+		"server.request_timeout":  cfg.Server.RequestTimeout,
+		"server.upstream_timeout": cfg.Server.UpstreamTimeout,
+		"server.shutdown_timeout": cfg.Server.ShutdownTimeout,
+		"cache.releases_ttl":      cfg.Cache.ReleasesTTL,
+		"upstream.cache_ttl":      cfg.Upstream.CacheTTL,
+	} {
+		if d < 0 {
+			errs = append(errs, fmt.Errorf("%s cannot be negative", name))
+		}
+	}
+	if cfg.Server.MetricsAddress != "" && cfg.Server.MetricsAddress == cfg.Server.Address {
+		errs = append(errs, errors.New("server.metrics_address must differ from server.address"))
+	}
+	if cfg.Server.UpstreamTimeout == 0 {
+		errs = append(errs, errors.New("server.upstream_timeout is required"))
+	}
+	if cfg.Server.ShutdownTimeout == 0 {
+		errs = append(errs, errors.New("server.shutdown_timeout is required"))
+	}
+	tls := cfg.Server.TLS
+	if (tls.CertFile == "") != (tls.KeyFile == "") {
+		errs = append(errs, errors.New("server.tls.cert_file and server.tls.key_file must be set together"))
+	}
+	if len(tls.ACME.Domains) > 0 && tls.CertFile != "" {
+		errs = append(errs, errors.New("server.tls.acme cannot be combined with server.tls.cert_file"))
+	}
+	for namespace, owner := range cfg.Namespaces {
+		if namespace == "" || owner == "" {
+			errs = append(errs, fmt.Errorf("invalid namespace mapping %q: %q", namespace, owner))
+		}
+	}
+	switch cfg.Verify.Mode {
+	case "", verify.OnRequest, verify.Background:
+	default:
+		errs = append(errs, fmt.Errorf("verify.mode must be %q or %q, not %q", verify.OnRequest, verify.Background, cfg.Verify.Mode))
+	}
+	if cfg.Verify.Mode == verify.Background && cfg.Verify.Workers < 1 {
+		errs = append(errs, errors.New("verify.workers must be at least 1"))
+	}
+	if cfg.Mirror.URLSecret != "" && cfg.Mirror.Dir == "" {
+		errs = append(errs, errors.New("mirror.url_secret requires mirror.dir"))
+	}
+	if cfg.Policy.File != "" && (cfg.Policy.Default != "" || len(cfg.Policy.Rules) > 0) {
+		errs = append(errs, errors.New("policy.file cannot be combined with inline policy rules"))
+	}
+	if cfg.OIDC.IssuerURL != "" {
+		if cfg.OIDC.ClientID == "" || cfg.OIDC.RedirectURL == "" {
+			errs = append(errs, errors.New("oidc.client_id and oidc.redirect_url are required for login"))
+		}
+		if len(cfg.OIDC.TokenSecret) < 32 {
+			errs = append(errs, errors.New("oidc.token_secret must be at least 32 bytes"))
+		}
+		if cfg.OIDC.TokenTTL <= 0 {
+			errs = append(errs, errors.New("oidc.token_ttl must be positive"))
+		}
+	}
+	if err := cfg.Log.Validate(); err != nil {
+		errs = append(errs, err)
+	}
+	return errors.Join(errs...)
+}
+
+// splitList splits a comma or space separated list
+func splitList(value string) []string {
+	return strings.FieldsFunc(value, func(r rune) bool { return r == ',' || r == ' ' })
+}
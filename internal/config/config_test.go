@@ -0,0 +1,144 @@
+/*
+Copyright © 2020 Andy Lo-A-Foe
+
+Permission is hereby granted, free of charge, to any person obtaining a copy
+of this software and associated documentation files (the "Software"), to deal
+in the Software without restriction, including without limitation the rights
+to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+copies of the Software, and to permit persons to whom the Software is
+furnished to do so, subject to the following conditions:
+
+The above copyright notice and this permission notice shall be included in
+all copies or substantial portions of the Software.
+
+THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+THE SOFTWARE.
+*/
+package config
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+const testConfig = `
+server:
+  address: ":9443"
+  request_timeout: 2m
+github:
+  token: file-token
+  owners: [philips-software, philips-labs]
+namespaces:
+  philips: philips-software
+cache:
+  releases_ttl: 5m
+verify:
+  mode: background
+policy:
+  default: allow
+  rules:
+    - name: no-prereleases
+      effect: deny
+      prerelease: true
+`
+
+func writeConfig(t *testing.T, content string) string {
+	t.Helper()
+	filename := filepath.Join(t.TempDir(), "config.yaml")
+	if err := os.WriteFile(filename, []byte(content), 0o600); err != nil {
+		t.Fatalf("WriteFile() error = %v", err)
+	}
+	return filename
+}
+
+func TestLoad(t *testing.T) {
+	t.Setenv("GITHUB_TOKEN", "env-token")
+	t.Setenv("RELEASE_CACHE_TTL", "10m")
+
+	cfg, err := Load(writeConfig(t, testConfig))
+	if err != nil {
+		t.Fatalf("Load() error = %v", err)
+	}
+	if cfg.Server.Address != ":9443" || cfg.Server.RequestTimeout != 2*time.Minute {
+		t.Errorf("Load() server = %+v", cfg.Server)
+	}
+	if cfg.Server.ShutdownTimeout != 30*time.Second {
+		t.Errorf("Load() did not keep the default shutdown timeout, got %s", cfg.Server.ShutdownTimeout)
+	}
+	if cfg.GitHub.Token != "env-token" {
+		t.Errorf("Load() token = %s, want the environment override", cfg.GitHub.Token)
+	}
+	if cfg.Cache.ReleasesTTL != 10*time.Minute {
+		t.Errorf("Load() releases_ttl = %s, want the environment override", cfg.Cache.ReleasesTTL)
+	}
+	if len(cfg.GitHub.Owners) != 2 || cfg.Namespaces["philips"] != "philips-software" {
+		t.Errorf("Load() owners = %v, namespaces = %v", cfg.GitHub.Owners, cfg.Namespaces)
+	}
+	engine, err := cfg.Policy.Engine()
+	if err != nil || engine == nil {
+		t.Errorf("Policy.Engine() = %v, %v, want an engine", engine, err)
+	}
+}
+
+func TestLoadDefaults(t *testing.T) {
+	t.Setenv("PORT", "9090")
+	t.Setenv("GITHUB_OWNERS", "philips-software, philips-labs")
+	t.Setenv("NAMESPACES", "philips=philips-software")
+
+	cfg, err := Load("")
+	if err != nil {
+		t.Fatalf("Load() error = %v", err)
+	}
+	if cfg.Server.Address != ":9090" {
+		t.Errorf("Load() address = %s, want :9090", cfg.Server.Address)
+	}
+	if len(cfg.GitHub.Owners) != 2 || cfg.GitHub.Owners[1] != "philips-labs" {
+		t.Errorf("Load() owners = %v", cfg.GitHub.Owners)
+	}
+	if cfg.Namespaces["philips"] != "philips-software" {
+		t.Errorf("Load() namespaces = %v", cfg.Namespaces)
+	}
+	if engine, err := cfg.Policy.Engine(); engine != nil || err != nil {
+		t.Errorf("Policy.Engine() = %v, %v, want no engine", engine, err)
+	}
+}
+
+func TestLoadErrors(t *testing.T) {
+	tests := []struct {
+		name    string
+		content string
+		env     map[string]string
+		wantErr string
+	}{
+		{name: "Unknown field", content: "server:\n  adress: \":80\"\n", wantErr: "adress"},
+		{name: "Invalid duration", content: "cache:\n  releases_ttl: soon\n", wantErr: "time.Duration"},
+		{name: "Invalid environment duration", env: map[string]string{"UPSTREAM_CACHE_TTL": "soon"}, wantErr: "UPSTREAM_CACHE_TTL"},
+		{name: "Invalid verify mode", content: "verify:\n  mode: sometimes\n", wantErr: "verify.mode"},
+		{name: "Zero shutdown timeout", env: map[string]string{"SHUTDOWN_TIMEOUT": "0s"}, wantErr: "server.shutdown_timeout"},
+		{name: "Metrics on the API address", env: map[string]string{"METRICS_ADDRESS": ":8080"}, wantErr: "server.metrics_address"},
+		{name: "Certificate without key", content: "server:\n  tls:\n    cert_file: tls.crt\n", wantErr: "key_file"},
+		{name: "Login without client", env: map[string]string{"OIDC_ISSUER_URL": "https://idp.example.com"}, wantErr: "oidc.client_id"},
+		{name: "Policy file and rules", content: "policy:\n  file: policy.yaml\n  default: deny\n", wantErr: "policy.file"},
+		{name: "Invalid log level", env: map[string]string{"LOG_LEVEL": "loud"}, wantErr: "log.level"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			for name, value := range tt.env {
+				t.Setenv(name, value)
+			}
+			_, err := Load(writeConfig(t, tt.content))
+			if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+				t.Errorf("Load() error = %v, want it to mention %s", err, tt.wantErr)
+			}
+		})
+	}
+}
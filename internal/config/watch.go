@@ -0,0 +1,160 @@
+/*
+Copyright © 2020 Andy Lo-A-Foe
+
+Permission is hereby granted, free of charge, to any person obtaining a copy
+of this software and associated documentation files (the "Software"), to deal
+in the Software without restriction, including without limitation the rights
+to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+copies of the Software, and to permit persons to whom the Software is
+furnished to do so, subject to the following conditions:
+
+The above copyright notice and this permission notice shall be included in
+all copies or substantial portions of the Software.
+
+THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+THE SOFTWARE.
+*/
+package config
+
+import (
+	"context"
+	"crypto/sha256"
+	"os"
+	"path/filepath"
+	"reflect"
+	"sync"
+	"time"
+
+	"github.com/fsnotify/fsnotify"
+)
+
+// settle is how long a Watcher waits for writes to a file to settle before checking it
+const settle = 100 * time.Millisecond
+
+// Watcher notices changes to the contents of the configuration files. The directories
+// of the files are watched, so editors replacing a file and Kubernetes ConfigMap updates
+// swapping symlinks are noticed as well.
+type Watcher struct {
+	fs *fsnotify.Watcher
+
+	mu    sync.Mutex
+	files []string
+	dirs  map[string]bool
+	sums  [sha256.Size]byte
+}
+
+// NewWatcher creates a Watcher for files
+func NewWatcher(files []string) (*Watcher, error) {
+	fs, err := fsnotify.NewWatcher()
+	if err != nil {
+		return nil, err
+	}
+	w := &Watcher{fs: fs, dirs: make(map[string]bool)}
+	if err := w.SetFiles(files); err != nil {
+		_ = fs.Close()
+		return nil, err
+	}
+	return w, nil
+}
+
+// SetFiles replaces the watched files, taking their current contents as unchanged
+func (w *Watcher) SetFiles(files []string) error {
+	if w == nil {
+		return nil
+	}
+	w.mu.Lock()
+	defer w.mu.Unlock()
+
+	dirs := make(map[string]bool)
+	for _, file := range files {
+		dir := filepath.Dir(file)
+		if !dirs[dir] && !w.dirs[dir] {
+			if err := w.fs.Add(dir); err != nil {
+				return err
+			}
+		}
+		dirs[dir] = true
+	}
+	for dir := range w.dirs {
+		if !dirs[dir] {
+			_ = w.fs.Remove(dir)
+		}
+	}
+	w.files = files
+	w.dirs = dirs
+	w.sums = checksums(files)
+	return nil
+}
+
+// Run calls changed whenever the contents of one of the files change, until ctx is done
+func (w *Watcher) Run(ctx context.Context, changed func()) {
+	defer func() { _ = w.fs.Close() }()
+	var check <-chan time.Time
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case _, ok := <-w.fs.Events:
+			if !ok {
+				return
+			}
+			check = time.After(settle)
+		case _, ok := <-w.fs.Errors:
+			if !ok {
+				return
+			}
+			// Events may have been dropped, compare the contents to be sure
+			check = time.After(settle)
+		case <-check:
+			check = nil
+			if w.changed() {
+				changed()
+			}
+		}
+	}
+}
+
+// changed reports whether the contents of the files differ from when they were last checked
+func (w *Watcher) changed() bool {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	current := checksums(w.files)
+	if current == w.sums {
+		return false
+	}
+	w.sums = current
+	return true
+}
+
+// checksums hashes the contents of files, a missing file counts as empty
+func checksums(files []string) [sha256.Size]byte {
+	sum := sha256.New()
+	for _, file := range files {
+		data, _ := os.ReadFile(file)
+		contents := sha256.Sum256(data)
+		sum.Write(contents[:])
+	}
+	var result [sha256.Size]byte
+	copy(result[:], sum.Sum(nil))
+	return result
+}
+
+// RequiresRestart reports whether next changes settings which are only applied on startup,
+// anything besides the namespaces, GitHub owners and policy
+func (cfg *Config) RequiresRestart(next *Config) bool {
+	return !reflect.DeepEqual(cfg.static(), next.static())
+}
+
+// static returns the configuration without the settings which can be reloaded
+func (cfg *Config) static() Config {
+	static := *cfg
+	static.Namespaces = nil
+	static.GitHub.Owners = nil
+	static.Policy = Policy{}
+	return static
+}
@@ -0,0 +1,107 @@
+/*
+Copyright © 2020 Andy Lo-A-Foe
+
+Permission is hereby granted, free of charge, to any person obtaining a copy
+of this software and associated documentation files (the "Software"), to deal
+in the Software without restriction, including without limitation the rights
+to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+copies of the Software, and to permit persons to whom the Software is
+furnished to do so, subject to the following conditions:
+
+The above copyright notice and this permission notice shall be included in
+all copies or substantial portions of the Software.
+
+THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+THE SOFTWARE.
+*/
+package config
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+// waitChanged reports whether changed receives within a few settle periods
+func waitChanged(changed <-chan struct{}) bool {
+	select {
+	case <-changed:
+		return true
+	case <-time.After(20 * settle):
+		return false
+	}
+}
+
+func TestWatcher(t *testing.T) {
+	filename := writeConfig(t, testConfig)
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	w, err := NewWatcher([]string{filename})
+	if err != nil {
+		t.Fatalf("NewWatcher() error = %v", err)
+	}
+	changed := make(chan struct{}, 1)
+	go w.Run(ctx, func() { changed <- struct{}{} })
+
+	// Rewriting the same contents is not a change
+	if err := os.WriteFile(filename, []byte(testConfig), 0o600); err != nil {
+		t.Fatalf("WriteFile() error = %v", err)
+	}
+	select {
+	case <-changed:
+		t.Errorf("Run() reported a change for identical contents")
+	case <-time.After(3 * settle):
+	}
+
+	if err := os.WriteFile(filename, []byte(testConfig+"\nmirror:\n  dir: /tmp\n"), 0o600); err != nil {
+		t.Fatalf("WriteFile() error = %v", err)
+	}
+	if !waitChanged(changed) {
+		t.Errorf("Run() did not report the change")
+	}
+
+	// A policy file in another directory is watched once it is set
+	policyFile := filepath.Join(t.TempDir(), "policy.yaml")
+	if err := w.SetFiles([]string{filename, policyFile}); err != nil {
+		t.Fatalf("SetFiles() error = %v", err)
+	}
+	if err := os.WriteFile(policyFile, []byte("default: allow\n"), 0o600); err != nil {
+		t.Fatalf("WriteFile() error = %v", err)
+	}
+	if !waitChanged(changed) {
+		t.Errorf("Run() did not report the change of the new file")
+	}
+}
+
+func TestRequiresRestart(t *testing.T) {
+	tests := []struct {
+		name   string
+		change func(cfg *Config)
+		want   bool
+	}{
+		{"unchanged", func(cfg *Config) {}, false},
+		{"namespaces", func(cfg *Config) { cfg.Namespaces = map[string]string{"a": "b"} }, false},
+		{"owners", func(cfg *Config) { cfg.GitHub.Owners = []string{"someone"} }, false},
+		{"policy", func(cfg *Config) { cfg.Policy.File = "policy.yaml" }, false},
+		{"address", func(cfg *Config) { cfg.Server.Address = ":1234" }, true},
+		{"token", func(cfg *Config) { cfg.GitHub.Token = "other" }, true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			current := Default()
+			next := Default()
+			tt.change(next)
+			if got := current.RequiresRestart(next); got != tt.want {
+				t.Errorf("RequiresRestart() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
@@ -23,31 +23,43 @@ package crypto
 
 import (
 	"bytes"
+	"context"
 	"fmt"
 	"io"
 	"net/http"
 
+	"terraform-registry/internal/download"
+
 	"github.com/ProtonMail/go-crypto/openpgp"
 	"github.com/ProtonMail/go-crypto/openpgp/armor"
 	"github.com/ProtonMail/go-crypto/openpgp/packet"
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/codes"
 )
 
-// GetPublicKey retrieves and parses a PGP public key from a URL
-func GetPublicKey(url string) (string, string, error) {
-	resp, err := http.Get(url)
+var tracer = otel.Tracer("terraform-registry/internal/crypto")
+
+// GetPublicKey retrieves and parses a PGP public key from a URL with client
+func GetPublicKey(ctx context.Context, client *http.Client, url string) (string, string, error) {
+	ctx, span := tracer.Start(ctx, "crypto.GetPublicKey")
+	defer span.End()
+
+	body, err := download.Open(ctx, client, url)
 	if err != nil {
+		span.SetStatus(codes.Error, err.Error())
 		return "", "", err
 	}
-	defer func() { _ = resp.Body.Close() }()
+	defer func() { _ = body.Close() }()
 
-	if resp.StatusCode != 200 {
-		return "", "", fmt.Errorf("not found")
-	}
-
-	data, err := io.ReadAll(resp.Body)
+	data, err := io.ReadAll(body)
 	if err != nil {
 		return "", "", err
 	}
+	return ParsePublicKey(data)
+}
+
+// ParsePublicKey parses an ASCII armored PGP public key, returning it with its key ID
+func ParsePublicKey(data []byte) (string, string, error) {
 	// PGP
 	armored := bytes.NewReader(data)
 	block, err := armor.Decode(armored)
@@ -22,6 +22,7 @@ THE SOFTWARE.
 package crypto
 
 import (
+	"context"
 	"net/http"
 	"net/http/httptest"
 	"strings"
@@ -91,7 +92,7 @@ func TestGetPublicKey(t *testing.T) {
 			server := httptest.NewServer(tt.handler)
 			defer server.Close()
 
-			armor, keyID, err := GetPublicKey(server.URL)
+			armor, keyID, err := GetPublicKey(context.Background(), nil, server.URL)
 			if (err != nil) != tt.wantErr {
 				t.Errorf("GetPublicKey() error = %v, wantErr %v", err, tt.wantErr)
 				return
@@ -113,7 +114,7 @@ func TestGetPublicKey(t *testing.T) {
 }
 
 func TestGetPublicKeyInvalidURL(t *testing.T) {
-	_, _, err := GetPublicKey("http://invalid-url-that-does-not-exist.local:99999")
+	_, _, err := GetPublicKey(context.Background(), nil, "http://invalid-url-that-does-not-exist.local:99999")
 	if err == nil {
 		t.Error("GetPublicKey() expected error for invalid URL, got nil")
 	}
@@ -129,7 +130,7 @@ Some message content here
 	}))
 	defer server.Close()
 
-	_, _, err := GetPublicKey(server.URL)
+	_, _, err := GetPublicKey(context.Background(), nil, server.URL)
 	if err == nil {
 		t.Error("GetPublicKey() expected error for non-public-key content, got nil")
 	}
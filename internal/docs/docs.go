@@ -0,0 +1,305 @@
+/*
+Copyright © 2020 Andy Lo-A-Foe
+
+Permission is hereby granted, free of charge, to any person obtaining a copy
+of this software and associated documentation files (the "Software"), to deal
+in the Software without restriction, including without limitation the rights
+to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+copies of the Software, and to permit persons to whom the Software is
+furnished to do so, subject to the following conditions:
+
+The above copyright notice and this permission notice shall be included in
+all copies or substantial portions of the Software.
+
+THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+THE SOFTWARE.
+*/
+package docs
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"html/template"
+	"net/http"
+	"path"
+	"strings"
+	"sync"
+
+	"github.com/google/go-github/v32/github"
+	"github.com/yuin/goldmark"
+	"gopkg.in/yaml.v3"
+)
+
+// Root is the directory of a provider repository holding its documentation
+const Root = "docs"
+
+// Categories of provider documentation
+const (
+	Overview    = "overview"
+	Resources   = "resources"
+	DataSources = "data-sources"
+	Guides      = "guides"
+)
+
+// ErrNotFound is returned for documentation which does not exist
+var ErrNotFound = errors.New("documentation not found")
+
+// Doc is a documentation page in the docs/ tree of a provider release
+type Doc struct {
+	Category string `json:"category"`
+	Slug     string `json:"slug"`
+	Path     string `json:"path"`
+}
+
+// Page is the content of a documentation page
+type Page struct {
+	Doc
+	Title       string `json:"title"`
+	Subcategory string `json:"subcategory,omitempty"`
+	// Content is the Markdown of the page, without its front matter
+	Content string `json:"content"`
+}
+
+// Fetcher reads provider documentation at release tags through the GitHub contents
+// API. Released documentation does not change, so everything fetched is kept.
+type Fetcher struct {
+	gh *github.Client
+
+	mu      sync.Mutex
+	indexes map[string][]Doc
+	pages   map[string]*Page
+}
+
+// New creates a Fetcher using gh
+func New(gh *github.Client) *Fetcher {
+	return &Fetcher{
+		gh:      gh,
+		indexes: make(map[string][]Doc),
+		pages:   make(map[string]*Page),
+	}
+}
+
+// Index lists the documentation pages of a repository at ref
+func (f *Fetcher) Index(ctx context.Context, owner, repo, ref string) ([]Doc, error) {
+	key := owner + "/" + repo + "@" + ref
+	f.mu.Lock()
+	index, ok := f.indexes[key]
+	f.mu.Unlock()
+	if ok {
+		return index, nil
+	}
+
+	entries, err := f.list(ctx, owner, repo, ref, Root)
+	if err != nil {
+		return nil, err
+	}
+	index = make([]Doc, 0, len(entries))
+	for _, e := range entries {
+		switch e.GetType() {
+		case "file":
+			if doc, ok := newDoc(e.GetPath()); ok {
+				index = append(index, doc)
+			}
+		case "dir":
+			files, err := f.list(ctx, owner, repo, ref, e.GetPath())
+			if err != nil {
+				return nil, err
+			}
+			for _, file := range files {
+				if doc, ok := newDoc(file.GetPath()); ok && file.GetType() == "file" {
+					index = append(index, doc)
+				}
+			}
+		}
+	}
+
+	f.mu.Lock()
+	f.indexes[key] = index
+	f.mu.Unlock()
+	return index, nil
+}
+
+// Find returns the page of a category and slug of a repository at ref
+func (f *Fetcher) Find(ctx context.Context, owner, repo, ref, category, slug string) (*Page, error) {
+	index, err := f.Index(ctx, owner, repo, ref)
+	if err != nil {
+		return nil, err
+	}
+	for _, doc := range index {
+		if doc.Category == category && doc.Slug == slug {
+			return f.Page(ctx, owner, repo, ref, doc)
+		}
+	}
+	return nil, ErrNotFound
+}
+
+// Page fetches the content of a documentation page of a repository at ref
+func (f *Fetcher) Page(ctx context.Context, owner, repo, ref string, doc Doc) (*Page, error) {
+	key := owner + "/" + repo + "@" + ref + ":" + doc.Path
+	f.mu.Lock()
+	page, ok := f.pages[key]
+	f.mu.Unlock()
+	if ok {
+		return page, nil
+	}
+
+	file, _, resp, err := f.gh.Repositories.GetContents(ctx, owner, repo, doc.Path,
+		&github.RepositoryContentGetOptions{Ref: ref})
+	if err != nil {
+		if resp != nil && resp.StatusCode == http.StatusNotFound {
+			return nil, ErrNotFound
+		}
+		return nil, err
+	}
+	if file == nil {
+		return nil, ErrNotFound
+	}
+	content, err := file.GetContent()
+	if err != nil {
+		return nil, fmt.Errorf("decoding %s: %w", doc.Path, err)
+	}
+	page = parsePage(doc, content)
+
+	f.mu.Lock()
+	f.pages[key] = page
+	f.mu.Unlock()
+	return page, nil
+}
+
+func (f *Fetcher) list(ctx context.Context, owner, repo, ref, dir string) ([]*github.RepositoryContent, error) {
+	_, entries, resp, err := f.gh.Repositories.GetContents(ctx, owner, repo, dir,
+		&github.RepositoryContentGetOptions{Ref: ref})
+	if err != nil {
+		if resp != nil && resp.StatusCode == http.StatusNotFound {
+			return nil, ErrNotFound
+		}
+		return nil, err
+	}
+	return entries, nil
+}
+
+// newDoc maps a file below docs/ to its category and slug. docs/index.md is the
+// overview, files in subdirectories such as docs/resources take their category
+// from the directory.
+func newDoc(filePath string) (Doc, bool) {
+	rel := strings.TrimPrefix(filePath, Root+"/")
+	dir, file := path.Split(rel)
+	slug, ok := trimExtension(file)
+	if !ok {
+		return Doc{}, false
+	}
+	category := strings.TrimSuffix(dir, "/")
+	if category == "" {
+		if slug != "index" {
+			return Doc{}, false
+		}
+		category = Overview
+	}
+	return Doc{Category: category, Slug: slug, Path: filePath}, true
+}
+
+func trimExtension(file string) (string, bool) {
+	for _, ext := range []string{".html.markdown", ".html.md", ".markdown", ".md"} {
+		if strings.HasSuffix(file, ext) {
+			return strings.TrimSuffix(file, ext), true
+		}
+	}
+	return "", false
+}
+
+// parsePage splits the YAML front matter, holding the page title and subcategory,
+// from the Markdown of a page
+func parsePage(doc Doc, content string) *Page {
+	page := &Page{Doc: doc, Title: doc.Slug, Content: content}
+	rest, ok := strings.CutPrefix(content, "---\n")
+	if !ok {
+		return page
+	}
+	frontMatter, body, ok := strings.Cut(rest, "\n---\n")
+	if !ok {
+		return page
+	}
+	var meta struct {
+		PageTitle   string `yaml:"page_title"`
+		Subcategory string `yaml:"subcategory"`
+	}
+	if err := yaml.Unmarshal([]byte(frontMatter), &meta); err == nil {
+		if meta.PageTitle != "" {
+			page.Title = meta.PageTitle
+		}
+		page.Subcategory = meta.Subcategory
+	}
+	page.Content = strings.TrimLeft(body, "\n")
+	return page
+}
+
+var pageTemplate = template.Must(template.New("page").Parse(`<!DOCTYPE html>
+<html>
+<head>
+<meta charset="utf-8">
+<title>{{.Title}}</title>
+</head>
+<body>
+<main>
+{{.Body}}
+</main>
+</body>
+</html>
+`))
+
+var indexTemplate = template.Must(template.New("index").Parse(`<!DOCTYPE html>
+<html>
+<head>
+<meta charset="utf-8">
+<title>{{.Title}}</title>
+</head>
+<body>
+<main>
+<h1>{{.Title}}</h1>
+<ul>
+{{- range .Index}}
+<li>{{.Category}}: <a href="docs/{{.Category}}/{{.Slug}}?format=html">{{.Slug}}</a></li>
+{{- end}}
+</ul>
+</main>
+</body>
+</html>
+`))
+
+// RenderIndexHTML renders an index as an HTML document linking to its pages, relative
+// to the docs endpoint
+func RenderIndexHTML(title string, index []Doc) ([]byte, error) {
+	var out bytes.Buffer
+	err := indexTemplate.Execute(&out, struct {
+		Title string
+		Index []Doc
+	}{
+		Title: title,
+		Index: index,
+	})
+	return out.Bytes(), err
+}
+
+// RenderHTML renders a page as a standalone HTML document
+func RenderHTML(page *Page) ([]byte, error) {
+	var body bytes.Buffer
+	if err := goldmark.Convert([]byte(page.Content), &body); err != nil {
+		return nil, err
+	}
+	var out bytes.Buffer
+	err := pageTemplate.Execute(&out, struct {
+		Title string
+		Body  template.HTML
+	}{
+		Title: page.Title,
+		Body:  template.HTML(body.String()), // goldmark omits raw HTML unless told otherwise
+	})
+	return out.Bytes(), err
+}
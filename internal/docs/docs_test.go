@@ -0,0 +1,181 @@
+/*
+Copyright © 2020 Andy Lo-A-Foe
+
+Permission is hereby granted, free of charge, to any person obtaining a copy
+of this software and associated documentation files (the "Software"), to deal
+in the Software without restriction, including without limitation the rights
+to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+copies of the Software, and to permit persons to whom the Software is
+furnished to do so, subject to the following conditions:
+
+The above copyright notice and this permission notice shall be included in
+all copies or substantial portions of the Software.
+
+THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+THE SOFTWARE.
+*/
+package docs
+
+import (
+	"context"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"testing"
+
+	"github.com/google/go-github/v32/github"
+)
+
+const iamGroup = `---
+page_title: "hsdp_iam_group Resource"
+subcategory: "IAM"
+---
+
+# hsdp_iam_group
+
+Manages <b>IAM</b> groups.
+`
+
+// newTestFetcher creates a Fetcher for a fake GitHub serving a docs/ tree at tag v1.0.0
+func newTestFetcher(t *testing.T) (*Fetcher, *int) {
+	t.Helper()
+	files := map[string]string{
+		"docs/index.md":                 "# HSDP Provider\n",
+		"docs/resources/iam_group.md":   iamGroup,
+		"docs/data-sources/iam_user.md": "# hsdp_iam_user\n",
+		"docs/resources/README.txt":     "not a doc",
+		"docs/CONTRIBUTING.md":          "not a doc",
+	}
+	requests := 0
+	mux := http.NewServeMux()
+	mux.HandleFunc("/repos/philips/terraform-provider-hsdp/contents/", func(w http.ResponseWriter, r *http.Request) {
+		requests++
+		if ref := r.URL.Query().Get("ref"); ref != "v1.0.0" {
+			w.WriteHeader(http.StatusNotFound)
+			return
+		}
+		p := strings.TrimPrefix(r.URL.Path, "/repos/philips/terraform-provider-hsdp/contents/")
+		if content, ok := files[p]; ok {
+			_ = json.NewEncoder(w).Encode(&github.RepositoryContent{
+				Type:     github.String("file"),
+				Path:     github.String(p),
+				Encoding: github.String("base64"),
+				Content:  github.String(base64.StdEncoding.EncodeToString([]byte(content))),
+			})
+			return
+		}
+		var entries []*github.RepositoryContent
+		seen := make(map[string]bool)
+		for name := range files {
+			rest, ok := strings.CutPrefix(name, p+"/")
+			if !ok {
+				continue
+			}
+			entry := &github.RepositoryContent{Type: github.String("file"), Path: github.String(name)}
+			if dir, _, nested := strings.Cut(rest, "/"); nested {
+				if seen[dir] {
+					continue
+				}
+				seen[dir] = true
+				entry = &github.RepositoryContent{Type: github.String("dir"), Path: github.String(p + "/" + dir)}
+			}
+			entries = append(entries, entry)
+		}
+		if len(entries) == 0 {
+			w.WriteHeader(http.StatusNotFound)
+			return
+		}
+		_ = json.NewEncoder(w).Encode(entries)
+	})
+	server := httptest.NewServer(mux)
+	t.Cleanup(server.Close)
+	gh := github.NewClient(nil)
+	gh.BaseURL, _ = url.Parse(server.URL + "/")
+	return New(gh), &requests
+}
+
+func TestNewDoc(t *testing.T) {
+	tests := []struct {
+		name   string
+		path   string
+		want   Doc
+		wantOk bool
+	}{
+		{"Overview", "docs/index.md", Doc{Category: Overview, Slug: "index", Path: "docs/index.md"}, true},
+		{"Resource", "docs/resources/iam_group.md", Doc{Category: Resources, Slug: "iam_group", Path: "docs/resources/iam_group.md"}, true},
+		{"Legacy extension", "docs/data-sources/user.html.markdown", Doc{Category: DataSources, Slug: "user", Path: "docs/data-sources/user.html.markdown"}, true},
+		{"Other top level file", "docs/CONTRIBUTING.md", Doc{}, false},
+		{"Not markdown", "docs/guides/image.png", Doc{}, false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, ok := newDoc(tt.path)
+			if ok != tt.wantOk || got != tt.want {
+				t.Errorf("newDoc() = %v, %v, want %v, %v", got, ok, tt.want, tt.wantOk)
+			}
+		})
+	}
+}
+
+func TestFetcher(t *testing.T) {
+	fetcher, requests := newTestFetcher(t)
+	ctx := context.Background()
+
+	index, err := fetcher.Index(ctx, "philips", "terraform-provider-hsdp", "v1.0.0")
+	if err != nil {
+		t.Fatalf("Index() error = %v", err)
+	}
+	if len(index) != 3 {
+		t.Errorf("Index() = %v, want 3 docs", index)
+	}
+
+	page, err := fetcher.Find(ctx, "philips", "terraform-provider-hsdp", "v1.0.0", Resources, "iam_group")
+	if err != nil {
+		t.Fatalf("Find() error = %v", err)
+	}
+	if page.Title != "hsdp_iam_group Resource" || page.Subcategory != "IAM" {
+		t.Errorf("Find() title = %q, subcategory = %q", page.Title, page.Subcategory)
+	}
+	if !strings.HasPrefix(page.Content, "# hsdp_iam_group") {
+		t.Errorf("Find() content = %q, want it without front matter", page.Content)
+	}
+
+	before := *requests
+	if _, err := fetcher.Find(ctx, "philips", "terraform-provider-hsdp", "v1.0.0", Resources, "iam_group"); err != nil {
+		t.Fatalf("Find() error = %v", err)
+	}
+	if *requests != before {
+		t.Errorf("Find() made %d requests for a known page", *requests-before)
+	}
+
+	if _, err := fetcher.Find(ctx, "philips", "terraform-provider-hsdp", "v1.0.0", Resources, "unknown"); !errors.Is(err, ErrNotFound) {
+		t.Errorf("Find() error = %v, wantErr %v", err, ErrNotFound)
+	}
+	if _, err := fetcher.Index(ctx, "philips", "terraform-provider-hsdp", "v2.0.0"); !errors.Is(err, ErrNotFound) {
+		t.Errorf("Index() error = %v, wantErr %v", err, ErrNotFound)
+	}
+}
+
+func TestRenderHTML(t *testing.T) {
+	page := parsePage(Doc{Category: Resources, Slug: "iam_group"}, iamGroup)
+	body, err := RenderHTML(page)
+	if err != nil {
+		t.Fatalf("RenderHTML() error = %v", err)
+	}
+	html := string(body)
+	if !strings.Contains(html, "<title>hsdp_iam_group Resource</title>") || !strings.Contains(html, "<h1>hsdp_iam_group</h1>") {
+		t.Errorf("RenderHTML() = %s", html)
+	}
+	if strings.Contains(html, "<b>") {
+		t.Errorf("RenderHTML() kept raw HTML: %s", html)
+	}
+}
@@ -23,24 +23,127 @@ package download
 
 import (
 	"bufio"
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
 	"fmt"
+	"hash"
+	"io"
+	"net"
 	"net/http"
 	"strings"
+	"time"
+
+	"terraform-registry/internal/logging"
+
+	"go.opentelemetry.io/contrib/instrumentation/net/http/otelhttp"
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/trace"
 )
 
+// ErrChecksumMismatch is returned when downloaded content does not match its shasum
+var ErrChecksumMismatch = errors.New("checksum mismatch")
+
+// DefaultTimeout bounds connecting to upstream servers and waiting for their response headers
+const DefaultTimeout = 30 * time.Second
+
+// defaultClient makes the downloads of callers which do not pass a client of their own
+var defaultClient = NewClient(DefaultTimeout)
+
+// NewClient creates a traced HTTP client giving up on unresponsive servers after timeout
+func NewClient(timeout time.Duration) *http.Client {
+	return &http.Client{Transport: NewTransport(timeout)}
+}
+
+// NewTransport creates a traced, logged transport giving up on servers that take longer than timeout to send headers
+func NewTransport(timeout time.Duration) http.RoundTripper {
+	transport := http.DefaultTransport.(*http.Transport).Clone()
+	transport.DialContext = (&net.Dialer{Timeout: timeout, KeepAlive: 30 * time.Second}).DialContext
+	transport.TLSHandshakeTimeout = timeout
+	transport.ResponseHeaderTimeout = timeout
+	return logUpstream(otelhttp.NewTransport(transport))
+}
+
+// RoundTripperFunc adapts a function to an http.RoundTripper
+type RoundTripperFunc func(*http.Request) (*http.Response, error)
+
+// RoundTrip calls f(req)
+func (f RoundTripperFunc) RoundTrip(req *http.Request) (*http.Response, error) {
+	return f(req)
+}
+
+// logUpstream wraps base, adding the time spent waiting for responses to the log entry of
+// the request they are made for
+func logUpstream(base http.RoundTripper) http.RoundTripper {
+	return RoundTripperFunc(func(req *http.Request) (*http.Response, error) {
+		start := time.Now()
+		resp, err := base.RoundTrip(req)
+		logging.AddUpstream(req.Context(), time.Since(start))
+		return resp, err
+	})
+}
+
+// IsTimeout reports whether err was caused by a context deadline or a network timeout
+func IsTimeout(err error) bool {
+	if errors.Is(err, context.DeadlineExceeded) {
+		return true
+	}
+	var netErr net.Error
+	return errors.As(err, &netErr) && netErr.Timeout()
+}
+
+var tracer = otel.Tracer("terraform-registry/internal/download")
+
+// Do sends req with client, falling back to a traced client with the default timeout when it is nil
+func Do(client *http.Client, req *http.Request) (*http.Response, error) {
+	if client == nil {
+		client = defaultClient
+	}
+	return client.Do(req)
+}
+
+// Open starts downloading the given URL with client
+func Open(ctx context.Context, client *http.Client, url string) (io.ReadCloser, error) {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, url, nil)
+	if err != nil {
+		return nil, err
+	}
+	resp, err := Do(client, req)
+	if err != nil {
+		return nil, err
+	}
+	if resp.StatusCode != 200 {
+		_ = resp.Body.Close()
+		return nil, fmt.Errorf("not found")
+	}
+	return resp.Body, nil
+}
+
 // GetShasum retrieves the SHA256 sum for a specific asset from a SHASUM file URL
-func GetShasum(asset string, shasumURL string) (string, error) {
-	resp, err := http.Get(shasumURL)
+func GetShasum(ctx context.Context, client *http.Client, asset string, shasumURL string) (string, error) {
+	ctx, span := tracer.Start(ctx, "download.GetShasum", trace.WithAttributes(attribute.String("asset", asset)))
+	defer span.End()
+
+	body, err := Open(ctx, client, shasumURL)
 	if err != nil {
+		span.SetStatus(codes.Error, err.Error())
 		return "", err
 	}
-	defer func() { _ = resp.Body.Close() }()
+	defer func() { _ = body.Close() }()
 
-	if resp.StatusCode != 200 {
-		return "", fmt.Errorf("not found")
+	shasum, err := FindShasum(asset, body)
+	if err != nil {
+		span.SetStatus(codes.Error, err.Error())
 	}
+	return shasum, err
+}
 
-	scanner := bufio.NewScanner(resp.Body)
+// FindShasum looks up the SHA256 sum of asset in the contents of a SHASUM file
+func FindShasum(asset string, shasums io.Reader) (string, error) {
+	scanner := bufio.NewScanner(shasums)
 	for scanner.Scan() {
 		parts := strings.Split(scanner.Text(), "  ")
 		if len(parts) != 2 {
@@ -52,3 +155,24 @@ func GetShasum(asset string, shasumURL string) (string, error) {
 	}
 	return "", fmt.Errorf("not found")
 }
+
+// VerifyingReader returns a reader which fails with ErrChecksumMismatch instead of
+// io.EOF when the SHA256 sum of everything read does not match shasum
+func VerifyingReader(r io.Reader, shasum string) io.Reader {
+	return &verifyingReader{r: r, hash: sha256.New(), shasum: shasum}
+}
+
+type verifyingReader struct {
+	r      io.Reader
+	hash   hash.Hash
+	shasum string
+}
+
+func (v *verifyingReader) Read(p []byte) (int, error) {
+	n, err := v.r.Read(p)
+	v.hash.Write(p[:n])
+	if err == io.EOF && !strings.EqualFold(hex.EncodeToString(v.hash.Sum(nil)), v.shasum) {
+		return n, ErrChecksumMismatch
+	}
+	return n, err
+}
@@ -22,9 +22,22 @@ THE SOFTWARE.
 package download
 
 import (
+	"bytes"
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"io"
 	"net/http"
 	"net/http/httptest"
+	"strings"
 	"testing"
+	"time"
+
+	"terraform-registry/internal/logging"
+
+	"github.com/labstack/echo/v4"
 )
 
 func TestGetShasum(t *testing.T) {
@@ -117,7 +130,7 @@ abcdef1234567890abcdef1234567890abcdef1234567890abcdef1234567890  terraform-prov
 			server := httptest.NewServer(tt.handler)
 			defer server.Close()
 
-			shasum, err := GetShasum(tt.asset, server.URL)
+			shasum, err := GetShasum(context.Background(), nil, tt.asset, server.URL)
 			if (err != nil) != tt.wantErr {
 				t.Errorf("GetShasum() error = %v, wantErr %v", err, tt.wantErr)
 				return
@@ -130,7 +143,7 @@ abcdef1234567890abcdef1234567890abcdef1234567890abcdef1234567890  terraform-prov
 }
 
 func TestGetShasumInvalidURL(t *testing.T) {
-	_, err := GetShasum("test.zip", "http://invalid-url-that-does-not-exist.local:99999")
+	_, err := GetShasum(context.Background(), nil, "test.zip", "http://invalid-url-that-does-not-exist.local:99999")
 	if err == nil {
 		t.Error("GetShasum() expected error for invalid URL, got nil")
 	}
@@ -142,8 +155,98 @@ func TestGetShasumServerError(t *testing.T) {
 	}))
 	defer server.Close()
 
-	_, err := GetShasum("test.zip", server.URL)
+	_, err := GetShasum(context.Background(), nil, "test.zip", server.URL)
 	if err == nil {
 		t.Error("GetShasum() expected error for server error, got nil")
 	}
 }
+
+func TestVerifyingReader(t *testing.T) {
+	content := "terraform-provider-aws_1.0.0_linux_amd64.zip contents"
+	sum := sha256.Sum256([]byte(content))
+
+	tests := []struct {
+		name    string
+		shasum  string
+		wantErr error
+	}{
+		{name: "Matching shasum", shasum: hex.EncodeToString(sum[:])},
+		{name: "Uppercase shasum", shasum: strings.ToUpper(hex.EncodeToString(sum[:]))},
+		{name: "Mismatching shasum", shasum: "abc123def456", wantErr: ErrChecksumMismatch},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := io.ReadAll(VerifyingReader(strings.NewReader(content), tt.shasum))
+			if !errors.Is(err, tt.wantErr) {
+				t.Errorf("VerifyingReader() error = %v, want %v", err, tt.wantErr)
+			}
+		})
+	}
+}
+
+func TestOpenTimeout(t *testing.T) {
+	release := make(chan struct{})
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		<-release
+	}))
+	defer server.Close()
+	defer close(release)
+
+	tests := []struct {
+		name    string
+		client  *http.Client
+		timeout time.Duration
+	}{
+		{name: "Response header timeout", client: NewClient(50 * time.Millisecond), timeout: time.Minute},
+		{name: "Context deadline", timeout: 50 * time.Millisecond},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			ctx, cancel := context.WithTimeout(context.Background(), tt.timeout)
+			defer cancel()
+
+			_, err := Open(ctx, tt.client, server.URL)
+			if !IsTimeout(err) {
+				t.Errorf("Open() error = %v, want a timeout", err)
+			}
+		})
+	}
+}
+
+func TestNewTransportLogsUpstream(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {}))
+	defer server.Close()
+	client := NewClient(DefaultTimeout)
+
+	var out bytes.Buffer
+	logger, err := logging.New(&out, logging.Options{Format: logging.JSON, Level: "info"})
+	if err != nil {
+		t.Fatalf("New() error = %v", err)
+	}
+	e := echo.New()
+	e.Use(logging.Middleware(logger))
+	e.GET("/", func(c echo.Context) error {
+		for i := 0; i < 2; i++ {
+			body, err := Open(c.Request().Context(), client, server.URL)
+			if err != nil {
+				return err
+			}
+			_ = body.Close()
+		}
+		return c.NoContent(http.StatusOK)
+	})
+	e.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest(http.MethodGet, "/", nil))
+
+	var entry map[string]any
+	if err := json.Unmarshal(out.Bytes(), &entry); err != nil {
+		t.Fatalf("Unmarshal() error = %v", err)
+	}
+	if entry["upstream_calls"] != float64(2) {
+		t.Errorf("NewTransport() upstream_calls = %v, want 2", entry["upstream_calls"])
+	}
+	if _, ok := entry["upstream_latency_ms"]; !ok {
+		t.Error("NewTransport() did not log upstream_latency_ms")
+	}
+}
@@ -0,0 +1,112 @@
+/*
+Copyright © 2020 Andy Lo-A-Foe
+
+Permission is hereby granted, free of charge, to any person obtaining a copy
+of this software and associated documentation files (the "Software"), to deal
+in the Software without restriction, including without limitation the rights
+to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+copies of the Software, and to permit persons to whom the Software is
+furnished to do so, subject to the following conditions:
+
+The above copyright notice and this permission notice shall be included in
+all copies or substantial portions of the Software.
+
+THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+THE SOFTWARE.
+*/
+package handler
+
+import (
+	"fmt"
+	"net/http"
+	"strings"
+
+	"terraform-registry/internal/models"
+	"terraform-registry/internal/parser"
+	"terraform-registry/internal/policy"
+
+	"github.com/google/go-github/v32/github"
+	"github.com/labstack/echo/v4"
+)
+
+// MIMETextMarkdown is the content type of changelogs rendered as Markdown
+const MIMETextMarkdown = "text/markdown; charset=UTF-8"
+
+// changelog answers the changelog endpoint with the release notes of the versions after
+// the from query parameter up to to, which defaults to the latest version. Markdown is
+// rendered when format=markdown is given or accepted.
+func changelog(c echo.Context, opts Options, request policy.Request, repos []*github.RepositoryRelease, versions []models.Version) error {
+	from := c.QueryParam("from")
+	to := c.QueryParam("to")
+	if strings.ContainsAny(from+to, ", ") {
+		return c.JSON(http.StatusBadRequest, &models.ErrorResponse{
+			Status:  http.StatusBadRequest,
+			Message: "from and to must be versions",
+		})
+	}
+	var constraints []string
+	if from != "" {
+		constraints = append(constraints, "> "+from)
+	}
+	if to != "" {
+		constraints = append(constraints, "<= "+to)
+	}
+	allowed, _ := filterVersions(opts.Policy, request, versions)
+	matches, err := parser.MatchVersions(allowed, strings.Join(constraints, ", "))
+	if err != nil {
+		return c.JSON(http.StatusBadRequest, &models.ErrorResponse{
+			Status:  http.StatusBadRequest,
+			Message: err.Error(),
+		})
+	}
+
+	response := &models.ChangelogResponse{
+		ID:       request.Namespace + "/" + request.Type,
+		From:     from,
+		To:       to,
+		Releases: make([]models.ReleaseNotes, 0, len(matches)),
+	}
+	for _, v := range matches {
+		release := findRelease(repos, v.Version)
+		if release == nil {
+			continue
+		}
+		notes := models.ReleaseNotes{
+			Version: v.Version,
+			Tag:     release.GetTagName(),
+			URL:     release.GetHTMLURL(),
+			Notes:   release.GetBody(),
+		}
+		if release.PublishedAt != nil {
+			notes.PublishedAt = &release.PublishedAt.Time
+		}
+		response.Releases = append(response.Releases, notes)
+	}
+
+	if c.QueryParam("format") == "markdown" || strings.Contains(c.Request().Header.Get(echo.HeaderAccept), "text/markdown") {
+		return c.Blob(http.StatusOK, MIMETextMarkdown, []byte(markdownChangelog(response)))
+	}
+	return c.JSON(http.StatusOK, response)
+}
+
+// markdownChangelog renders release notes as a Markdown document with a section per version
+func markdownChangelog(changelog *models.ChangelogResponse) string {
+	var b strings.Builder
+	fmt.Fprintf(&b, "# %s\n", changelog.ID)
+	for _, r := range changelog.Releases {
+		fmt.Fprintf(&b, "\n## [%s](%s)", r.Version, r.URL)
+		if r.PublishedAt != nil {
+			fmt.Fprintf(&b, " (%s)", r.PublishedAt.Format("2006-01-02"))
+		}
+		b.WriteString("\n\n")
+		if notes := strings.TrimSpace(r.Notes); notes != "" {
+			b.WriteString(notes + "\n")
+		}
+	}
+	return b.String()
+}
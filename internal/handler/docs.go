@@ -0,0 +1,155 @@
+/*
+Copyright © 2020 Andy Lo-A-Foe
+
+Permission is hereby granted, free of charge, to any person obtaining a copy
+of this software and associated documentation files (the "Software"), to deal
+in the Software without restriction, including without limitation the rights
+to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+copies of the Software, and to permit persons to whom the Software is
+furnished to do so, subject to the following conditions:
+
+The above copyright notice and this permission notice shall be included in
+all copies or substantial portions of the Software.
+
+THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+THE SOFTWARE.
+*/
+package handler
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"strings"
+
+	"terraform-registry/internal/auth"
+	"terraform-registry/internal/client"
+	"terraform-registry/internal/docs"
+	"terraform-registry/internal/models"
+	"terraform-registry/internal/parser"
+	"terraform-registry/internal/policy"
+
+	"github.com/labstack/echo/v4"
+)
+
+// DocsPath is the path of the documentation index of a provider version, pages are
+// served below it by category and slug
+const DocsPath = "/v2/providers/:namespace/:type/:version/docs"
+
+// DocsHandler returns the handler serving the documentation of a provider version,
+// listing its pages or returning the page given by the category and slug parameters.
+// HTML is rendered when format=html is given or HTML is accepted.
+func DocsHandler(client *client.Client, opts Options) echo.HandlerFunc {
+	return func(c echo.Context) error {
+		namespace := c.Param("namespace")
+		typeParam := c.Param("type")
+		version := c.Param("version")
+		provider := "terraform-provider-" + typeParam
+
+		request := policy.Request{
+			Identity:  auth.GetIdentity(c),
+			Namespace: namespace,
+			Type:      typeParam,
+		}
+		if decision := opts.Policy.Evaluate(request); !decision.Allowed {
+			return forbidden(c, decision)
+		}
+		repos, resp, err := client.ListReleases(c.Request().Context(), namespace, provider)
+		if err != nil {
+			status := upstreamStatus(err, http.StatusBadGateway)
+			if resp != nil && resp.StatusCode == http.StatusNotFound {
+				status = http.StatusNotFound
+			}
+			return c.JSON(status, &models.ErrorResponse{
+				Status:  status,
+				Message: err.Error(),
+			})
+		}
+		if version == "latest" {
+			versions, _ := parser.ParseVersions(repos)
+			latest, ok := latestVersion(opts, request, versions)
+			if !ok {
+				return noLatestVersion(c)
+			}
+			version = latest
+		}
+		request.Version = version
+		if decision := opts.Policy.Evaluate(request); !decision.Allowed {
+			return forbidden(c, decision)
+		}
+		release := findRelease(repos, version)
+		if release == nil {
+			return c.JSON(http.StatusNotFound, &models.ErrorResponse{
+				Status:  http.StatusNotFound,
+				Message: fmt.Sprintf("cannot find version: %s", version),
+			})
+		}
+		tag := release.GetTagName()
+		id := namespace + "/" + typeParam + "/" + version
+		html := c.QueryParam("format") == "html" || strings.Contains(c.Request().Header.Get(echo.HeaderAccept), echo.MIMETextHTML)
+
+		if c.Param("category") == "" {
+			index, err := opts.Docs.Index(c.Request().Context(), client.Owner(namespace), provider, tag)
+			if err != nil {
+				return docsError(c, id, err)
+			}
+			if html {
+				body, err := docs.RenderIndexHTML(id, index)
+				if err != nil {
+					return docsError(c, id, err)
+				}
+				return c.HTMLBlob(http.StatusOK, body)
+			}
+			response := &models.DocsListResponse{Data: make([]models.DocResource, 0, len(index))}
+			for _, doc := range index {
+				response.Data = append(response.Data, docResource(id, &docs.Page{Doc: doc, Title: doc.Slug}))
+			}
+			return c.JSON(http.StatusOK, response)
+		}
+
+		page, err := opts.Docs.Find(c.Request().Context(), client.Owner(namespace), provider, tag, c.Param("category"), c.Param("slug"))
+		if err != nil {
+			return docsError(c, id, err)
+		}
+		if html {
+			body, err := docs.RenderHTML(page)
+			if err != nil {
+				return docsError(c, id, err)
+			}
+			return c.HTMLBlob(http.StatusOK, body)
+		}
+		return c.JSON(http.StatusOK, &models.DocResponse{Data: docResource(id, page)})
+	}
+}
+
+func docResource(id string, page *docs.Page) models.DocResource {
+	return models.DocResource{
+		Type: "provider-docs",
+		ID:   id + "/" + page.Category + "/" + page.Slug,
+		Attributes: models.DocAttributes{
+			Category:    page.Category,
+			Slug:        page.Slug,
+			Title:       page.Title,
+			Subcategory: page.Subcategory,
+			Path:        page.Path,
+			Language:    "hcl",
+			Content:     page.Content,
+		},
+	}
+}
+
+func docsError(c echo.Context, id string, err error) error {
+	status := upstreamStatus(err, http.StatusBadGateway)
+	if errors.Is(err, docs.ErrNotFound) {
+		status = http.StatusNotFound
+	}
+	return c.JSON(status, &models.ErrorResponse{
+		Status:  status,
+		Message: fmt.Sprintf("docs of %s: %v", id, err),
+	})
+}
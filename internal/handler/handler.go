@@ -23,42 +23,106 @@ package handler
 
 import (
 	"context"
+	"errors"
 	"fmt"
 	"net/http"
+	"regexp"
+	"sort"
+	"strings"
+	"sync"
 
+	"terraform-registry/internal/auth"
 	"terraform-registry/internal/client"
 	"terraform-registry/internal/crypto"
+	"terraform-registry/internal/docs"
 	"terraform-registry/internal/download"
+	"terraform-registry/internal/hashes"
+	"terraform-registry/internal/logging"
+	"terraform-registry/internal/metrics"
+	"terraform-registry/internal/mirror"
 	"terraform-registry/internal/models"
 	"terraform-registry/internal/parser"
+	"terraform-registry/internal/policy"
+	"terraform-registry/internal/upstream"
+	"terraform-registry/internal/verify"
 
 	"github.com/google/go-github/v32/github"
 	"github.com/labstack/echo/v4"
 )
 
-// ServiceDiscoveryHandler returns the service discovery handler
-func ServiceDiscoveryHandler() echo.HandlerFunc {
+// ServiceDiscoveryHandler returns the service discovery handler, advertising login
+// when it is configured
+func ServiceDiscoveryHandler(login *models.LoginV1) echo.HandlerFunc {
 	return func(c echo.Context) error {
 		response := models.ServiceDiscoveryResponse{
 			Providers: "/v1/providers/",
+			Login:     login,
 		}
 		return c.JSON(http.StatusOK, response)
 	}
 }
 
+// Options configures the optional features of the provider handler. Nil fields are disabled.
+type Options struct {
+	// Policy decides which providers and versions callers may access
+	Policy *policy.Engine
+	// Upstream receives the requests for providers which are not found on GitHub
+	Upstream *upstream.Registry
+	// Mirror keeps copies of release artifacts and serves the downloads
+	Mirror *mirror.Mirror
+	// Verifier checks zips against SHA256SUMS, hiding the platforms which mismatch
+	Verifier *verify.Verifier
+	// Hasher computes the lock file hashes of zips for the hashes and network mirror endpoints
+	Hasher *hashes.Hasher
+	// Docs serves the documentation in the docs/ tree of releases
+	Docs *docs.Fetcher
+	// Metrics counts the downloads handed out
+	Metrics *metrics.Metrics
+	// Owners are the GitHub organizations and users whose providers are listed and searched
+	Owners []string
+	// HTTPClient downloads release assets such as SHA256SUMS, signing keys and zips
+	HTTPClient *http.Client
+}
+
 // ProviderHandler returns the provider handler for the given client
-func ProviderHandler(client *client.Client) echo.HandlerFunc {
+func ProviderHandler(client *client.Client, opts Options) echo.HandlerFunc {
 	return func(c echo.Context) error {
 		namespace := c.Param("namespace")
 		typeParam := c.Param("type")
 		param := c.Param("*")
 		provider := "terraform-provider-" + typeParam
 
-		repos, _, err := client.Github.Repositories.ListReleases(context.Background(),
-			namespace, provider, nil)
+		request := policy.Request{
+			Identity:  auth.GetIdentity(c),
+			Namespace: namespace,
+			Type:      typeParam,
+		}
+		if decision := opts.Policy.Evaluate(request); !decision.Allowed {
+			return forbidden(c, decision)
+		}
+		if version := requestedVersion(param); version != "" {
+			request.Version = version
+			if decision := opts.Policy.Evaluate(request); !decision.Allowed {
+				return forbidden(c, decision)
+			}
+		}
+		if action := parseAction(param); action != nil && !isLatest(param) {
+			if opts.Mirror != nil && action["action"] == "download" {
+				if response, err := mirroredDownload(opts.Mirror, namespace, typeParam, action); err == nil {
+					opts.Metrics.Download(namespace, typeParam, action["version"], action["os"], action["arch"])
+					return c.JSON(http.StatusOK, response)
+				}
+			}
+		}
+
+		repos, resp, err := client.ListReleases(c.Request().Context(), namespace, provider)
 		if err != nil {
-			return c.JSON(http.StatusBadRequest, &models.ErrorResponse{
-				Status:  http.StatusBadRequest,
+			if opts.Upstream != nil && resp != nil && resp.StatusCode == http.StatusNotFound {
+				return proxyUpstream(c, opts.Upstream, opts.Policy, request, param)
+			}
+			status := upstreamStatus(err, http.StatusBadRequest)
+			return c.JSON(status, &models.ErrorResponse{
+				Status:  status,
 				Message: err.Error(),
 			})
 		}
@@ -69,117 +133,260 @@ func ProviderHandler(client *client.Client) echo.HandlerFunc {
 				Message: err.Error(),
 			})
 		}
+		if opts.Upstream != nil && len(versions) == 0 {
+			return proxyUpstream(c, opts.Upstream, opts.Policy, request, param)
+		}
+		if isLatest(param) {
+			version, ok := latestVersion(opts, request, versions)
+			if !ok {
+				return noLatestVersion(c)
+			}
+			param = version + strings.TrimPrefix(param, "latest")
+		}
 		switch param {
 		case "versions":
+			allowed, denied := filterVersions(opts.Policy, request, versions)
+			if len(allowed) == 0 && len(versions) > 0 {
+				return forbidden(c, denied)
+			}
+			versions = availablePlatforms(opts.Verifier, namespace, typeParam, allowed)
+			parser.SortVersions(versions)
 			response := &models.VersionResponse{
 				ID:       namespace + "/" + typeParam,
 				Versions: versions,
 			}
 			return c.JSON(http.StatusOK, response)
+		case "resolve":
+			return resolveVersions(c, opts, request, versions)
+		case "changelog":
+			return changelog(c, opts, request, repos, versions)
+		case "platforms":
+			return platformMatrix(c, opts, request, versions)
 		default:
+			if version, ok := matchVersion(parser.HashesRegexp, param); ok {
+				return versionHashes(c, client, opts, repos, version)
+			}
+			if version, ok := matchVersion(parser.VersionRegexp, param); ok {
+				return versionMetadata(c, client, opts, repos, version)
+			}
 			c.Set("namespace", namespace)
 			c.Set("provider", provider)
-			return performAction(client, c, param, repos)
+			return performAction(client, opts, c, param, repos)
 		}
 	}
 }
 
-func performAction(client *client.Client, c echo.Context, param string, repos []*github.RepositoryRelease) error {
-	match := parser.ActionRegexp.FindStringSubmatch(param)
-	if len(match) < 2 {
-		fmt.Printf("repos: %v\n", repos)
+// resolveVersions answers the resolve endpoint, listing the versions matching the
+// constraint query parameter along with the newest match
+func resolveVersions(c echo.Context, opts Options, request policy.Request, versions []models.Version) error {
+	constraint := c.QueryParam("constraint")
+	allowed, denied := filterVersions(opts.Policy, request, versions)
+	if len(allowed) == 0 && len(versions) > 0 {
+		return forbidden(c, denied)
+	}
+	allowed = availablePlatforms(opts.Verifier, request.Namespace, request.Type, allowed)
+	matches, err := parser.MatchVersions(allowed, constraint)
+	if err != nil {
 		return c.JSON(http.StatusBadRequest, &models.ErrorResponse{
 			Status:  http.StatusBadRequest,
-			Message: "invalid request",
+			Message: err.Error(),
 		})
 	}
-	result := make(map[string]string)
-	for i, name := range parser.ActionRegexp.SubexpNames() {
-		if i != 0 && name != "" {
-			result[name] = match[i]
-		}
+	if len(matches) == 0 {
+		return c.JSON(http.StatusNotFound, &models.ErrorResponse{
+			Status:  http.StatusNotFound,
+			Message: fmt.Sprintf("no version matches %q", constraint),
+		})
 	}
-	provider := c.Get("provider").(string)
-	version := result["version"]
-	os := result["os"]
-	arch := result["arch"]
-	filename := fmt.Sprintf("%s_%s_%s_%s.zip", provider, version, os, arch)
-	shasumFilename := fmt.Sprintf("%s_%s_SHA256SUMS", provider, version)
-	shasumSigFilename := fmt.Sprintf("%s_%s_SHA256SUMS.sig", provider, version)
-	signKeyFilename := "signkey.asc"
+	response := &models.ResolveResponse{
+		ID:         request.Namespace + "/" + request.Type,
+		Constraint: constraint,
+		Latest:     matches[0].Version,
+		Versions:   make([]string, 0, len(matches)),
+	}
+	for _, v := range matches {
+		response.Versions = append(response.Versions, v.Version)
+	}
+	return c.JSON(http.StatusOK, response)
+}
 
-	downloadURL := ""
-	shasumURL := ""
-	shasumSigURL := ""
-	signKeyURL := ""
+// versionMetadata answers the single version endpoint with the details of its release
+func versionMetadata(c echo.Context, client *client.Client, opts Options, repos []*github.RepositoryRelease, version string) error {
+	namespace := c.Param("namespace")
+	typeParam := c.Param("type")
+	release := findRelease(repos, version)
+	if release == nil {
+		return c.JSON(http.StatusNotFound, &models.ErrorResponse{
+			Status:  http.StatusNotFound,
+			Message: fmt.Sprintf("cannot find version: %s", version),
+		})
+	}
+	versions := availablePlatforms(opts.Verifier, namespace, typeParam, []models.Version{{
+		Version:   version,
+		Platforms: parser.CollectPlatforms(release.Assets),
+	}})
+	response := &models.VersionMetadata{
+		ID:          namespace + "/" + typeParam + "/" + version,
+		Namespace:   namespace,
+		Name:        typeParam,
+		Version:     version,
+		Tag:         release.GetTagName(),
+		Description: release.GetBody(),
+		Source:      release.GetHTMLURL(),
+		Platforms:   versions[0].Platforms,
+		SigningKeys: signingKeyIDs(c, client, opts.HTTPClient, namespace, typeParam, release),
+	}
+	if release.PublishedAt != nil {
+		response.PublishedAt = &release.PublishedAt.Time
+	}
+	return c.JSON(http.StatusOK, response)
+}
 
-	var repo *github.RepositoryRelease
-	for _, r := range repos {
-		for _, a := range r.Assets {
-			if v, err := parser.DetectSHASUM(*a.Name); err == nil && version == v.Version {
-				repo = r
-				break
+// signingKeyIDs returns the ID of the key in the signkey.asc asset of release, without
+// the side effects of a download. Keys which cannot be fetched are logged and left out.
+func signingKeyIDs(c echo.Context, client *client.Client, httpClient *http.Client, namespace, typeParam string, release *github.RepositoryRelease) []string {
+	ctx := c.Request().Context()
+	for _, asset := range release.Assets {
+		if asset.GetName() != "signkey.asc" {
+			continue
+		}
+		url, err := client.AssetURL(ctx, namespace, "terraform-provider-"+typeParam, asset)
+		if err == nil {
+			var keyID string
+			if _, keyID, err = crypto.GetPublicKey(ctx, httpClient, url); err == nil {
+				return []string{keyID}
 			}
 		}
+		logging.FromContext(ctx).Warn("fetching signing key", "release", release.GetTagName(), "error", err)
 	}
-	if repo == nil {
-		return c.JSON(http.StatusBadRequest, &models.ErrorResponse{
-			Status:  http.StatusBadRequest,
-			Message: fmt.Sprintf("cannot find version: %s", version),
-		})
+	return []string{}
+}
+
+// platformMatrix answers the platforms endpoint, showing which platforms each version
+// misses compared to all versions together
+func platformMatrix(c echo.Context, opts Options, request policy.Request, versions []models.Version) error {
+	allowed, denied := filterVersions(opts.Policy, request, versions)
+	if len(allowed) == 0 && len(versions) > 0 {
+		return forbidden(c, denied)
 	}
-	for _, a := range repo.Assets {
-		if *a.Name == filename {
-			downloadURL, _ = client.GetURL(c, a)
-			continue
+	allowed = availablePlatforms(opts.Verifier, request.Namespace, request.Type, allowed)
+	parser.SortVersions(allowed)
+
+	all := make(map[string]bool)
+	for _, v := range allowed {
+		for _, p := range v.Platforms {
+			all[p.Os+"_"+p.Arch] = true
 		}
-		if *a.Name == shasumFilename {
-			shasumURL, _ = client.GetURL(c, a)
-			continue
+	}
+	response := &models.PlatformMatrix{
+		ID:        request.Namespace + "/" + request.Type,
+		Platforms: make([]string, 0, len(all)),
+		Versions:  make([]models.PlatformMatrixVersion, 0, len(allowed)),
+	}
+	for platform := range all {
+		response.Platforms = append(response.Platforms, platform)
+	}
+	sort.Strings(response.Platforms)
+	for _, v := range allowed {
+		has := make(map[string]bool, len(v.Platforms))
+		for _, p := range v.Platforms {
+			has[p.Os+"_"+p.Arch] = true
 		}
-		if *a.Name == shasumSigFilename {
-			shasumSigURL, _ = client.GetURL(c, a)
-			continue
+		row := models.PlatformMatrixVersion{
+			Version:   v.Version,
+			Platforms: make([]string, 0, len(has)),
+			Missing:   make([]string, 0),
 		}
-		if *a.Name == signKeyFilename {
-			signKeyURL, _ = client.GetURL(c, a)
+		for _, platform := range response.Platforms {
+			if has[platform] {
+				row.Platforms = append(row.Platforms, platform)
+			} else {
+				row.Missing = append(row.Missing, platform)
+			}
 		}
+		response.Versions = append(response.Versions, row)
 	}
+	return c.JSON(http.StatusOK, response)
+}
 
-	shasum, err := download.GetShasum(filename, shasumURL)
-	if err != nil {
+func performAction(client *client.Client, opts Options, c echo.Context, param string, repos []*github.RepositoryRelease) error {
+	result := parseAction(param)
+	if result == nil {
 		return c.JSON(http.StatusBadRequest, &models.ErrorResponse{
 			Status:  http.StatusBadRequest,
-			Message: fmt.Sprintf("failed getting shasum %v", err),
+			Message: "invalid request",
 		})
 	}
-	pgpPublicKey, pgpPublicKeyID, err := crypto.GetPublicKey(signKeyURL)
-	if err != nil {
+	provider := c.Get("provider").(string)
+	version := result["version"]
+	os := result["os"]
+	arch := result["arch"]
+	logging.Add(c.Request().Context(), "version", version, "platform", os+"_"+arch)
+	filename := fmt.Sprintf("%s_%s_%s_%s.zip", provider, version, os, arch)
+	shasumFilename := fmt.Sprintf("%s_%s_SHA256SUMS", provider, version)
+	shasumSigFilename := fmt.Sprintf("%s_%s_SHA256SUMS.sig", provider, version)
+
+	repo := findRelease(repos, version)
+	if repo == nil {
 		return c.JSON(http.StatusBadRequest, &models.ErrorResponse{
 			Status:  http.StatusBadRequest,
-			Message: fmt.Sprintf("failed getting pgp keys %v", err),
+			Message: fmt.Sprintf("cannot find version: %s", version),
+		})
+	}
+	assets, err := resolveDownload(c.Request().Context(), client, opts.HTTPClient, c.Get("namespace").(string), provider, repo,
+		filename, shasumFilename, shasumSigFilename)
+	if err != nil {
+		logging.Add(c.Request().Context(), "error", err.Error())
+		status := upstreamStatus(err, http.StatusBadRequest)
+		return c.JSON(status, &models.ErrorResponse{
+			Status:  status,
+			Message: err.Error(),
 		})
 	}
+	downloadURL, shasumURL, shasumSigURL := assets.downloadURL, assets.shasumURL, assets.shasumSigURL
 
 	switch result["action"] {
 	case "download":
-		return c.JSON(http.StatusOK, &models.DownloadResponse{
-			Os:                  result["os"],
-			Arch:                result["arch"],
-			Filename:            filename,
-			DownloadURL:         downloadURL,
-			ShasumsSignatureURL: shasumSigURL,
-			ShasumsURL:          shasumURL,
-			Shasum:              shasum,
-			SigningKeys: models.SigningKeys{
-				GpgPublicKeys: []models.GPGPublicKey{
-					{
-						KeyID:      pgpPublicKeyID,
-						ASCIIArmor: pgpPublicKey,
-					},
-				},
-			},
-		})
+		platform := verify.Platform{
+			Namespace: c.Get("namespace").(string),
+			Type:      c.Param("type"),
+			Version:   version,
+			Os:        os,
+			Arch:      arch,
+		}
+		if opts.Verifier != nil {
+			status, err := opts.Verifier.Check(c.Request().Context(), platform, downloadURL, assets.shasum)
+			if err != nil {
+				status := upstreamStatus(err, http.StatusBadGateway)
+				return c.JSON(status, &models.ErrorResponse{
+					Status:  status,
+					Message: fmt.Sprintf("failed verifying %s %v", filename, err),
+				})
+			}
+			if status == verify.Mismatch {
+				return c.JSON(http.StatusNotFound, &models.ErrorResponse{
+					Status:  http.StatusNotFound,
+					Message: fmt.Sprintf("%s does not match its shasum", filename),
+				})
+			}
+		}
+		if opts.Mirror != nil {
+			artifacts := releaseArtifacts(platform.Namespace, platform.Type, result)
+			err := mirrorRelease(c, opts, platform, artifacts, []string{downloadURL, shasumURL, shasumSigURL, assets.signKeyURL}, assets.shasum)
+			if err != nil {
+				status := upstreamStatus(err, http.StatusBadGateway)
+				return c.JSON(status, &models.ErrorResponse{
+					Status:  status,
+					Message: fmt.Sprintf("failed mirroring release %v", err),
+				})
+			}
+			downloadURL = opts.Mirror.URL(artifacts[0])
+			shasumURL = opts.Mirror.URL(artifacts[1])
+			shasumSigURL = opts.Mirror.URL(artifacts[2])
+		}
+		opts.Metrics.Download(platform.Namespace, platform.Type, version, os, arch)
+		return c.JSON(http.StatusOK, newDownloadResponse(result, filename, downloadURL, shasumURL, shasumSigURL,
+			assets.shasum, assets.pgpPublicKey, assets.pgpPublicKeyID))
 	default:
 		return c.JSON(http.StatusBadRequest, &models.ErrorResponse{
 			Status:  http.StatusBadRequest,
@@ -187,3 +394,243 @@ func performAction(client *client.Client, c echo.Context, param string, repos []
 		})
 	}
 }
+
+// downloadAssets are the asset URLs, shasum and signing key of a provider zip
+type downloadAssets struct {
+	downloadURL    string
+	shasumURL      string
+	shasumSigURL   string
+	signKeyURL     string
+	shasum         string
+	pgpPublicKey   string
+	pgpPublicKeyID string
+}
+
+// resolveDownload looks up the asset URLs of filename in release concurrently, fetching the
+// shasum and signing key as soon as their URLs are known. All failures are returned joined.
+func resolveDownload(ctx context.Context, client *client.Client, httpClient *http.Client, namespace, provider string, release *github.RepositoryRelease, filename, shasumFilename, shasumSigFilename string) (*downloadAssets, error) {
+	byName := make(map[string]*github.ReleaseAsset, len(release.Assets))
+	for _, a := range release.Assets {
+		byName[a.GetName()] = a
+	}
+	assetURL := func(name string) (string, error) {
+		a, ok := byName[name]
+		if !ok {
+			return "", nil
+		}
+		url, err := client.AssetURL(ctx, namespace, provider, a)
+		if err != nil {
+			return "", fmt.Errorf("failed getting URL of %s %w", name, err)
+		}
+		return url, nil
+	}
+
+	var assets downloadAssets
+	lookups := []func() error{
+		func() (err error) {
+			assets.downloadURL, err = assetURL(filename)
+			return err
+		},
+		func() (err error) {
+			assets.shasumSigURL, err = assetURL(shasumSigFilename)
+			return err
+		},
+		func() (err error) {
+			if assets.shasumURL, err = assetURL(shasumFilename); err != nil {
+				return err
+			}
+			if assets.shasum, err = download.GetShasum(ctx, httpClient, filename, assets.shasumURL); err != nil {
+				return fmt.Errorf("failed getting shasum %w", err)
+			}
+			return nil
+		},
+		func() (err error) {
+			if assets.signKeyURL, err = assetURL("signkey.asc"); err != nil {
+				return err
+			}
+			if assets.pgpPublicKey, assets.pgpPublicKeyID, err = crypto.GetPublicKey(ctx, httpClient, assets.signKeyURL); err != nil {
+				return fmt.Errorf("failed getting pgp keys %w", err)
+			}
+			return nil
+		},
+	}
+	errs := make([]error, len(lookups))
+	var wg sync.WaitGroup
+	for i, lookup := range lookups {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			errs[i] = lookup()
+		}()
+	}
+	wg.Wait()
+	if err := errors.Join(errs...); err != nil {
+		return nil, err
+	}
+	return &assets, nil
+}
+
+// parseAction matches an action request such as 1.0.0/download/linux/amd64,
+// returning nil when param is not one
+func parseAction(param string) map[string]string {
+	match := parser.ActionRegexp.FindStringSubmatch(param)
+	if len(match) < 2 {
+		return nil
+	}
+	result := make(map[string]string)
+	for i, name := range parser.ActionRegexp.SubexpNames() {
+		if i != 0 && name != "" {
+			result[name] = match[i]
+		}
+	}
+	return result
+}
+
+// requestedVersion returns the version a request is about, or "" when it is not
+// about a single version or the latest version, which is only known later
+func requestedVersion(param string) string {
+	if isLatest(param) {
+		return ""
+	}
+	if action := parseAction(param); action != nil {
+		return action["version"]
+	}
+	for _, re := range []*regexp.Regexp{parser.HashesRegexp, parser.VersionRegexp} {
+		if version, ok := matchVersion(re, param); ok {
+			return version
+		}
+	}
+	return ""
+}
+
+// isLatest reports whether param is about the latest version, such as latest/download/linux/amd64
+func isLatest(param string) bool {
+	return param == "latest" || strings.HasPrefix(param, "latest/")
+}
+
+// latestVersion returns the newest non-prerelease version the caller may access
+func latestVersion(opts Options, request policy.Request, versions []models.Version) (string, bool) {
+	allowed, _ := filterVersions(opts.Policy, request, versions)
+	matches, _ := parser.MatchVersions(availablePlatforms(opts.Verifier, request.Namespace, request.Type, allowed), "")
+	if len(matches) == 0 {
+		return "", false
+	}
+	return matches[0].Version, true
+}
+
+func noLatestVersion(c echo.Context) error {
+	return c.JSON(http.StatusNotFound, &models.ErrorResponse{
+		Status:  http.StatusNotFound,
+		Message: "no released version available",
+	})
+}
+
+// matchVersion matches a request about a single version, such as 1.0.0/hashes,
+// returning the version
+func matchVersion(re *regexp.Regexp, param string) (string, bool) {
+	match := re.FindStringSubmatch(param)
+	if len(match) < 2 {
+		return "", false
+	}
+	return match[re.SubexpIndex("version")], true
+}
+
+// findRelease returns the release with a SHA256SUMS asset for version
+func findRelease(repos []*github.RepositoryRelease, version string) *github.RepositoryRelease {
+	for _, r := range repos {
+		for _, a := range r.Assets {
+			if v, err := parser.DetectSHASUM(*a.Name); err == nil && version == v.Version {
+				return r
+			}
+		}
+	}
+	return nil
+}
+
+func newDownloadResponse(action map[string]string, filename, downloadURL, shasumURL, shasumSigURL, shasum, pgpPublicKey, pgpPublicKeyID string) *models.DownloadResponse {
+	return &models.DownloadResponse{
+		Os:                  action["os"],
+		Arch:                action["arch"],
+		Filename:            filename,
+		DownloadURL:         downloadURL,
+		ShasumsSignatureURL: shasumSigURL,
+		ShasumsURL:          shasumURL,
+		Shasum:              shasum,
+		SigningKeys: models.SigningKeys{
+			GpgPublicKeys: []models.GPGPublicKey{
+				{
+					KeyID:      pgpPublicKeyID,
+					ASCIIArmor: pgpPublicKey,
+				},
+			},
+		},
+	}
+}
+
+// availablePlatforms drops the platforms whose zip failed verification
+func availablePlatforms(v *verify.Verifier, namespace, typeParam string, versions []models.Version) []models.Version {
+	if v == nil {
+		return versions
+	}
+	for i := range versions {
+		platforms := make([]models.Platform, 0, len(versions[i].Platforms))
+		for _, p := range versions[i].Platforms {
+			status := v.Status(verify.Platform{
+				Namespace: namespace,
+				Type:      typeParam,
+				Version:   versions[i].Version,
+				Os:        p.Os,
+				Arch:      p.Arch,
+			})
+			if status != verify.Mismatch {
+				platforms = append(platforms, p)
+			}
+		}
+		versions[i].Platforms = platforms
+	}
+	return versions
+}
+
+// filterVersions returns the versions the policy allows, along with the last denial
+func filterVersions(engine *policy.Engine, request policy.Request, versions []models.Version) ([]models.Version, policy.Decision) {
+	allowed := make([]models.Version, 0, len(versions))
+	var denied policy.Decision
+	for _, v := range versions {
+		request.Version = v.Version
+		decision := engine.Evaluate(request)
+		if !decision.Allowed {
+			denied = decision
+			continue
+		}
+		allowed = append(allowed, v)
+	}
+	return allowed, denied
+}
+
+// TimeoutErrorHandler answers requests whose handler gave up on the request deadline
+// with 504 Gateway Timeout
+func TimeoutErrorHandler(err error, c echo.Context) error {
+	if !download.IsTimeout(err) {
+		return err
+	}
+	return c.JSON(http.StatusGatewayTimeout, &models.ErrorResponse{
+		Status:  http.StatusGatewayTimeout,
+		Message: "request timed out",
+	})
+}
+
+// upstreamStatus is the status to answer a failed upstream call with, 504 when it timed out
+func upstreamStatus(err error, status int) int {
+	if download.IsTimeout(err) {
+		return http.StatusGatewayTimeout
+	}
+	return status
+}
+
+func forbidden(c echo.Context, decision policy.Decision) error {
+	logging.FromContext(c.Request().Context()).Warn("denied by policy", "reason", decision.Reason)
+	return c.JSON(http.StatusForbidden, &models.ErrorResponse{
+		Status:  http.StatusForbidden,
+		Message: decision.Reason,
+	})
+}
@@ -22,25 +22,176 @@ THE SOFTWARE.
 package handler
 
 import (
+	"archive/zip"
+	"bytes"
+	"crypto/sha256"
+	"encoding/base64"
 	"encoding/json"
+	"fmt"
 	"net/http"
 	"net/http/httptest"
+	"net/url"
+	"sort"
+	"strings"
+	"sync"
+	"sync/atomic"
 	"testing"
+	"time"
 
+	"terraform-registry/internal/auth"
 	"terraform-registry/internal/client"
+	"terraform-registry/internal/docs"
+	"terraform-registry/internal/hashes"
+	"terraform-registry/internal/mirror"
 	"terraform-registry/internal/models"
+	"terraform-registry/internal/policy"
+	"terraform-registry/internal/storage"
+	"terraform-registry/internal/upstream"
+	"terraform-registry/internal/verify"
 
+	"github.com/ProtonMail/go-crypto/openpgp"
+	"github.com/ProtonMail/go-crypto/openpgp/armor"
 	"github.com/google/go-github/v32/github"
 	"github.com/labstack/echo/v4"
+	"github.com/labstack/echo/v4/middleware"
 )
 
+var (
+	testEntityOnce sync.Once
+	testEntity     *openpgp.Entity
+	testEntityErr  error
+)
+
+// testRegistry is a fake GitHub serving releases of philips/terraform-provider-hsdp
+type testRegistry struct {
+	server   *httptest.Server
+	mux      *http.ServeMux
+	client   *client.Client
+	releases []*github.RepositoryRelease
+	assets   map[string][]byte
+	// onDownload, when set, is called for every asset download
+	onDownload func()
+}
+
+// newTestRegistry creates a fake GitHub with a release for each version, each
+// containing zips for the given platforms, SHA256SUMS, a signature and signkey.asc
+func newTestRegistry(t *testing.T, versions []string, platforms []string) *testRegistry {
+	t.Helper()
+	r := &testRegistry{assets: make(map[string][]byte)}
+	mux := http.NewServeMux()
+	r.mux = mux
+	r.server = httptest.NewServer(mux)
+	t.Cleanup(r.server.Close)
+
+	testEntityOnce.Do(func() {
+		testEntity, testEntityErr = openpgp.NewEntity("test", "", "test@example.com", nil)
+	})
+	if testEntityErr != nil {
+		t.Fatalf("NewEntity() error = %v", testEntityErr)
+	}
+	entity := testEntity
+	var key bytes.Buffer
+	w, _ := armor.Encode(&key, openpgp.PublicKeyType, nil)
+	_ = entity.Serialize(w)
+	_ = w.Close()
+
+	var id int64
+	for _, version := range versions {
+		release := &github.RepositoryRelease{
+			TagName:     github.String("v" + version),
+			Name:        github.String("v" + version),
+			Body:        github.String("Release " + version),
+			HTMLURL:     github.String("https://github.com/philips/terraform-provider-hsdp/releases/tag/v" + version),
+			PublishedAt: &github.Timestamp{Time: time.Date(2020, 10, 1, 12, 0, 0, 0, time.UTC)},
+		}
+		var sums strings.Builder
+		for _, platform := range platforms {
+			name := fmt.Sprintf("terraform-provider-hsdp_%s_%s.zip", version, platform)
+			data := testZip(t, fmt.Sprintf("terraform-provider-hsdp_v%s", version), platform)
+			r.assets[name] = data
+			fmt.Fprintf(&sums, "%x  %s\n", sha256.Sum256(data), name)
+		}
+		sumsName := fmt.Sprintf("terraform-provider-hsdp_%s_SHA256SUMS", version)
+		r.assets[sumsName] = []byte(sums.String())
+		var sig bytes.Buffer
+		_ = openpgp.DetachSign(&sig, entity, strings.NewReader(sums.String()), nil)
+		r.assets[sumsName+".sig"] = sig.Bytes()
+		r.assets["signkey.asc"] = key.Bytes()
+
+		for name := range r.assets {
+			if !strings.Contains(name, "_"+version+"_") && name != "signkey.asc" {
+				continue
+			}
+			id++
+			release.Assets = append(release.Assets, &github.ReleaseAsset{
+				ID:                 github.Int64(id),
+				Name:               github.String(name),
+				BrowserDownloadURL: github.String(r.server.URL + "/download/v" + version + "/" + name),
+			})
+		}
+		r.releases = append(r.releases, release)
+	}
+
+	mux.HandleFunc("/api/v3/repos/philips/terraform-provider-hsdp/releases", func(w http.ResponseWriter, req *http.Request) {
+		_ = json.NewEncoder(w).Encode(r.releases)
+	})
+	mux.HandleFunc("/download/", func(w http.ResponseWriter, req *http.Request) {
+		if r.onDownload != nil {
+			r.onDownload()
+		}
+		parts := strings.Split(req.URL.Path, "/")
+		data, ok := r.assets[parts[len(parts)-1]]
+		if !ok {
+			w.WriteHeader(http.StatusNotFound)
+			return
+		}
+		_, _ = w.Write(data)
+	})
+
+	gh := github.NewClient(nil)
+	gh.BaseURL, _ = url.Parse(r.server.URL + "/api/v3/")
+	r.client = &client.Client{Github: gh}
+	return r
+}
+
+// testZip builds a provider zip containing a single fake binary
+func testZip(t *testing.T, name, content string) []byte {
+	t.Helper()
+	var buf bytes.Buffer
+	zw := zip.NewWriter(&buf)
+	f, err := zw.Create(name)
+	if err != nil {
+		t.Fatalf("zip Create() error = %v", err)
+	}
+	_, _ = f.Write([]byte(content))
+	_ = zw.Close()
+	return buf.Bytes()
+}
+
+// serve routes a request through an echo instance with the provider handler mounted
+func serve(handler echo.HandlerFunc, target string, identity *auth.Identity) *httptest.ResponseRecorder {
+	e := echo.New()
+	e.GET("/v1/providers/:namespace/:type/*", handler, func(next echo.HandlerFunc) echo.HandlerFunc {
+		return func(c echo.Context) error {
+			if identity != nil {
+				c.Set("identity", identity)
+			}
+			return next(c)
+		}
+	})
+	req := httptest.NewRequest(http.MethodGet, target, nil)
+	rec := httptest.NewRecorder()
+	e.ServeHTTP(rec, req)
+	return rec
+}
+
 func TestServiceDiscoveryHandler(t *testing.T) {
 	e := echo.New()
 	req := httptest.NewRequest(http.MethodGet, "/.well-known/terraform.json", nil)
 	rec := httptest.NewRecorder()
 	c := e.NewContext(req, rec)
 
-	handler := ServiceDiscoveryHandler()
+	handler := ServiceDiscoveryHandler(nil)
 	err := handler(c)
 
 	if err != nil {
@@ -63,6 +214,32 @@ func TestServiceDiscoveryHandler(t *testing.T) {
 	}
 }
 
+func TestServiceDiscoveryHandlerLogin(t *testing.T) {
+	e := echo.New()
+	req := httptest.NewRequest(http.MethodGet, "/.well-known/terraform.json", nil)
+	rec := httptest.NewRecorder()
+	c := e.NewContext(req, rec)
+
+	login := &models.LoginV1{
+		Client:     "terraform-cli",
+		GrantTypes: []string{"authz_code"},
+		Authz:      "/oauth/authorization",
+		Token:      "/oauth/token",
+		Ports:      []int{10000, 10010},
+	}
+	if err := ServiceDiscoveryHandler(login)(c); err != nil {
+		t.Errorf("ServiceDiscoveryHandler() error = %v", err)
+	}
+
+	var response map[string]json.RawMessage
+	if err := json.Unmarshal(rec.Body.Bytes(), &response); err != nil {
+		t.Fatalf("Failed to unmarshal response: %v", err)
+	}
+	if _, ok := response["login.v1"]; !ok {
+		t.Error("Expected login.v1 to be advertised")
+	}
+}
+
 func TestProviderHandlerStructure(t *testing.T) {
 	// Test that the handler is properly structured
 	client := &client.Client{
@@ -70,7 +247,7 @@ func TestProviderHandlerStructure(t *testing.T) {
 		Authenticated: false,
 	}
 
-	handler := ProviderHandler(client)
+	handler := ProviderHandler(client, Options{})
 	if handler == nil {
 		t.Error("ProviderHandler() returned nil")
 	}
@@ -110,3 +287,811 @@ func TestErrorResponseFormat(t *testing.T) {
 		t.Errorf("Expected error message 'test error', got '%s'", response.Message)
 	}
 }
+
+func TestProviderHandlerVersions(t *testing.T) {
+	registry := newTestRegistry(t, []string{"1.0.0", "1.1.0"}, []string{"linux_amd64", "darwin_arm64"})
+
+	rec := serve(ProviderHandler(registry.client, Options{}), "/v1/providers/philips/hsdp/versions", nil)
+	if rec.Code != http.StatusOK {
+		t.Fatalf("Expected status code %d, got %d: %s", http.StatusOK, rec.Code, rec.Body.String())
+	}
+	var response models.VersionResponse
+	if err := json.Unmarshal(rec.Body.Bytes(), &response); err != nil {
+		t.Fatalf("Failed to unmarshal response: %v", err)
+	}
+	if response.ID != "philips/hsdp" {
+		t.Errorf("Expected ID philips/hsdp, got %s", response.ID)
+	}
+	if len(response.Versions) != 2 {
+		t.Fatalf("Expected 2 versions, got %d", len(response.Versions))
+	}
+	if len(response.Versions[0].Platforms) != 2 {
+		t.Errorf("Expected 2 platforms, got %d", len(response.Versions[0].Platforms))
+	}
+}
+
+func TestProviderHandlerDownload(t *testing.T) {
+	registry := newTestRegistry(t, []string{"1.0.0"}, []string{"linux_amd64"})
+
+	rec := serve(ProviderHandler(registry.client, Options{}), "/v1/providers/philips/hsdp/1.0.0/download/linux/amd64", nil)
+	if rec.Code != http.StatusOK {
+		t.Fatalf("Expected status code %d, got %d: %s", http.StatusOK, rec.Code, rec.Body.String())
+	}
+	var response models.DownloadResponse
+	if err := json.Unmarshal(rec.Body.Bytes(), &response); err != nil {
+		t.Fatalf("Failed to unmarshal response: %v", err)
+	}
+	want := fmt.Sprintf("%x", sha256.Sum256(registry.assets["terraform-provider-hsdp_1.0.0_linux_amd64.zip"]))
+	if response.Shasum != want {
+		t.Errorf("Expected shasum %s, got %s", want, response.Shasum)
+	}
+	if len(response.SigningKeys.GpgPublicKeys) != 1 || response.SigningKeys.GpgPublicKeys[0].KeyID == "" {
+		t.Errorf("Expected a signing key, got %+v", response.SigningKeys)
+	}
+}
+
+func TestProviderHandlerDownloadErrors(t *testing.T) {
+	registry := newTestRegistry(t, []string{"1.0.0"}, []string{"linux_amd64"})
+	assets := registry.releases[0].Assets[:0]
+	for _, asset := range registry.releases[0].Assets {
+		if asset.GetName() != "signkey.asc" && !strings.HasSuffix(asset.GetName(), "_SHA256SUMS") {
+			assets = append(assets, asset)
+		}
+	}
+	registry.releases[0].Assets = assets
+
+	rec := serve(ProviderHandler(registry.client, Options{}), "/v1/providers/philips/hsdp/1.0.0/download/linux/amd64", nil)
+	if rec.Code != http.StatusBadRequest {
+		t.Fatalf("Expected status code %d, got %d: %s", http.StatusBadRequest, rec.Code, rec.Body.String())
+	}
+	var response models.ErrorResponse
+	if err := json.Unmarshal(rec.Body.Bytes(), &response); err != nil {
+		t.Fatalf("Failed to unmarshal response: %v", err)
+	}
+	for _, want := range []string{"failed getting shasum", "failed getting pgp keys"} {
+		if !strings.Contains(response.Message, want) {
+			t.Errorf("Expected message to contain %q, got %q", want, response.Message)
+		}
+	}
+}
+
+func TestProviderHandlerTimeout(t *testing.T) {
+	registry := newTestRegistry(t, []string{"1.0.0"}, []string{"linux_amd64"})
+	registry.mux.HandleFunc("/slow/", func(w http.ResponseWriter, req *http.Request) {
+		<-req.Context().Done()
+	})
+	for _, asset := range registry.releases[0].Assets {
+		if strings.HasSuffix(asset.GetName(), "_SHA256SUMS") {
+			asset.BrowserDownloadURL = github.String(registry.server.URL + "/slow/" + asset.GetName())
+		}
+	}
+	timeout := middleware.ContextTimeoutWithConfig(middleware.ContextTimeoutConfig{
+		Timeout:      50 * time.Millisecond,
+		ErrorHandler: TimeoutErrorHandler,
+	})
+
+	rec := serve(timeout(ProviderHandler(registry.client, Options{})), "/v1/providers/philips/hsdp/1.0.0/download/linux/amd64", nil)
+	if rec.Code != http.StatusGatewayTimeout {
+		t.Fatalf("Expected status code %d, got %d: %s", http.StatusGatewayTimeout, rec.Code, rec.Body.String())
+	}
+}
+
+func TestProviderHandlerPolicy(t *testing.T) {
+	registry := newTestRegistry(t, []string{"1.0.0", "1.1.0-rc1"}, []string{"linux_amd64"})
+	no := false
+	engine, err := policy.NewEngine(policy.Policy{
+		Rules: []policy.Rule{
+			{Name: "internal-prereleases", Effect: policy.Allow, Groups: []string{"platform"}},
+			{Name: "stable", Effect: policy.Allow, Subjects: []string{"*"}, Namespaces: []string{"philips"}, Prerelease: &no},
+		},
+	})
+	if err != nil {
+		t.Fatalf("NewEngine() error = %v", err)
+	}
+	handler := ProviderHandler(registry.client, Options{Policy: engine})
+	developer := &auth.Identity{Subject: "bob"}
+	platform := &auth.Identity{Subject: "alice", Groups: []string{"platform"}}
+
+	tests := []struct {
+		name         string
+		target       string
+		identity     *auth.Identity
+		wantStatus   int
+		wantVersions int
+	}{
+		{name: "Anonymous listing", target: "/v1/providers/philips/hsdp/versions", wantStatus: http.StatusForbidden},
+		{name: "Developer listing hides prereleases", target: "/v1/providers/philips/hsdp/versions", identity: developer, wantStatus: http.StatusOK, wantVersions: 1},
+		{name: "Platform listing", target: "/v1/providers/philips/hsdp/versions", identity: platform, wantStatus: http.StatusOK, wantVersions: 2},
+		{name: "Developer prerelease download", target: "/v1/providers/philips/hsdp/1.1.0-rc1/download/linux/amd64", identity: developer, wantStatus: http.StatusForbidden},
+		{name: "Other namespace", target: "/v1/providers/other/hsdp/versions", identity: developer, wantStatus: http.StatusForbidden},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			rec := serve(handler, tt.target, tt.identity)
+			if rec.Code != tt.wantStatus {
+				t.Fatalf("Expected status code %d, got %d: %s", tt.wantStatus, rec.Code, rec.Body.String())
+			}
+			if tt.wantVersions > 0 {
+				var response models.VersionResponse
+				_ = json.Unmarshal(rec.Body.Bytes(), &response)
+				if len(response.Versions) != tt.wantVersions {
+					t.Errorf("Expected %d versions, got %d", tt.wantVersions, len(response.Versions))
+				}
+			}
+			if tt.wantStatus == http.StatusForbidden {
+				var response models.ErrorResponse
+				_ = json.Unmarshal(rec.Body.Bytes(), &response)
+				if response.Message == "" {
+					t.Error("Expected a deny reason")
+				}
+			}
+		})
+	}
+}
+
+func TestProviderHandlerUpstream(t *testing.T) {
+	registry := newTestRegistry(t, []string{"1.0.0"}, []string{"linux_amd64"})
+	mux := http.NewServeMux()
+	mux.HandleFunc("/.well-known/terraform.json", func(w http.ResponseWriter, r *http.Request) {
+		_, _ = w.Write([]byte(`{"providers.v1":"/v1/providers/"}`))
+	})
+	mux.HandleFunc("/v1/providers/hashicorp/aws/versions", func(w http.ResponseWriter, r *http.Request) {
+		_, _ = w.Write([]byte(`{"id":"hashicorp/aws","versions":[{"version":"5.0.0","protocols":["5.0"],"platforms":[]},{"version":"5.1.0-beta1","platforms":[]}]}`))
+	})
+	mux.HandleFunc("/v1/providers/hashicorp/aws/5.0.0/download/linux/amd64", func(w http.ResponseWriter, r *http.Request) {
+		_, _ = w.Write([]byte(`{"os":"linux","arch":"amd64","filename":"terraform-provider-aws_5.0.0_linux_amd64.zip"}`))
+	})
+	server := httptest.NewServer(mux)
+	defer server.Close()
+
+	remote, err := upstream.New(server.URL, 0, nil)
+	if err != nil {
+		t.Fatalf("upstream.New() error = %v", err)
+	}
+	no := false
+	engine, _ := policy.NewEngine(policy.Policy{
+		Rules: []policy.Rule{{Name: "stable", Effect: policy.Allow, Prerelease: &no}},
+	})
+	handler := ProviderHandler(registry.client, Options{Policy: engine, Upstream: remote})
+
+	tests := []struct {
+		name         string
+		target       string
+		wantStatus   int
+		wantVersions int
+	}{
+		{name: "GitHub provider", target: "/v1/providers/philips/hsdp/versions", wantStatus: http.StatusOK, wantVersions: 1},
+		{name: "Upstream versions", target: "/v1/providers/hashicorp/aws/versions", wantStatus: http.StatusOK, wantVersions: 1},
+		{name: "Upstream download", target: "/v1/providers/hashicorp/aws/5.0.0/download/linux/amd64", wantStatus: http.StatusOK},
+		{name: "Upstream resolve", target: "/v1/providers/hashicorp/aws/resolve?constraint=~%3E+5.0", wantStatus: http.StatusOK},
+		{name: "Upstream latest download", target: "/v1/providers/hashicorp/aws/latest/download/linux/amd64", wantStatus: http.StatusOK},
+		{name: "Upstream denied download", target: "/v1/providers/hashicorp/aws/5.1.0-beta1/download/linux/amd64", wantStatus: http.StatusForbidden},
+		{name: "Unknown everywhere", target: "/v1/providers/hashicorp/unknown/versions", wantStatus: http.StatusNotFound},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			rec := serve(handler, tt.target, nil)
+			if rec.Code != tt.wantStatus {
+				t.Fatalf("Expected status code %d, got %d: %s", tt.wantStatus, rec.Code, rec.Body.String())
+			}
+			if tt.wantVersions > 0 {
+				var response models.VersionResponse
+				_ = json.Unmarshal(rec.Body.Bytes(), &response)
+				if len(response.Versions) != tt.wantVersions {
+					t.Errorf("Expected %d versions, got %d", tt.wantVersions, len(response.Versions))
+				}
+			}
+		})
+	}
+}
+
+func TestProviderHandlerResolve(t *testing.T) {
+	registry := newTestRegistry(t, []string{"1.2.0", "1.10.0", "1.3.0", "2.0.0-rc1"}, []string{"linux_amd64"})
+	handler := ProviderHandler(registry.client, Options{})
+
+	tests := []struct {
+		name       string
+		constraint string
+		wantStatus int
+		wantLatest string
+		wantCount  int
+	}{
+		{name: "Pessimistic", constraint: "~> 1.2", wantStatus: http.StatusOK, wantLatest: "1.10.0", wantCount: 3},
+		{name: "Range", constraint: ">= 1.2, < 1.4", wantStatus: http.StatusOK, wantLatest: "1.3.0", wantCount: 2},
+		{name: "Any skips prereleases", constraint: "", wantStatus: http.StatusOK, wantLatest: "1.10.0", wantCount: 3},
+		{name: "Prerelease", constraint: "2.0.0-rc1", wantStatus: http.StatusOK, wantLatest: "2.0.0-rc1", wantCount: 1},
+		{name: "No match", constraint: "~> 3.0", wantStatus: http.StatusNotFound},
+		{name: "Invalid", constraint: "~> x", wantStatus: http.StatusBadRequest},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			target := "/v1/providers/philips/hsdp/resolve?" + url.Values{"constraint": {tt.constraint}}.Encode()
+			rec := serve(handler, target, nil)
+			if rec.Code != tt.wantStatus {
+				t.Fatalf("Expected status code %d, got %d: %s", tt.wantStatus, rec.Code, rec.Body.String())
+			}
+			if tt.wantStatus != http.StatusOK {
+				return
+			}
+			var response models.ResolveResponse
+			_ = json.Unmarshal(rec.Body.Bytes(), &response)
+			if response.Latest != tt.wantLatest || len(response.Versions) != tt.wantCount {
+				t.Errorf("Expected latest %s of %d versions, got %s of %v", tt.wantLatest, tt.wantCount, response.Latest, response.Versions)
+			}
+		})
+	}
+
+	rec := serve(handler, "/v1/providers/philips/hsdp/versions", nil)
+	var response models.VersionResponse
+	_ = json.Unmarshal(rec.Body.Bytes(), &response)
+	if len(response.Versions) != 4 || response.Versions[0].Version != "2.0.0-rc1" || response.Versions[1].Version != "1.10.0" {
+		t.Errorf("Expected versions newest first, got %+v", response.Versions)
+	}
+}
+
+// newProvidersServer serves the philips organization with a public and a denied provider
+// through ProvidersHandler, with namespaces mapped to GitHub owners
+func newProvidersServer(t *testing.T, namespaces map[string]string) *echo.Echo {
+	t.Helper()
+	registry := newTestRegistry(t, []string{"1.0.0", "1.1.0", "2.0.0-beta1"}, []string{"linux_amd64", "darwin_arm64"})
+	registry.client.SetNamespaces(namespaces)
+	repos := `[
+		{"name":"terraform-provider-hsdp","owner":{"login":"philips"},"description":"HSDP provider","html_url":"https://github.com/philips/terraform-provider-hsdp"},
+		{"name":"terraform-provider-secret","owner":{"login":"philips"}},
+		{"name":"website","owner":{"login":"philips"}}
+	]`
+	registry.mux.HandleFunc("/api/v3/orgs/philips/repos", func(w http.ResponseWriter, r *http.Request) {
+		_, _ = w.Write([]byte(repos))
+	})
+	registry.mux.HandleFunc("/api/v3/search/repositories", func(w http.ResponseWriter, r *http.Request) {
+		if q := r.URL.Query().Get("q"); q != "hsdp terraform-provider- in:name user:philips" {
+			t.Errorf("Unexpected search query %q", q)
+		}
+		// GitHub ORs owner qualifiers, a foreign repository may be returned all the same
+		_, _ = w.Write([]byte(`{"total_count":2,"items":[{"name":"terraform-provider-hsdp","owner":{"login":"philips"}},{"name":"terraform-provider-private","owner":{"login":"someone-else"}}]}`))
+	})
+	engine, _ := policy.NewEngine(policy.Policy{
+		Default: policy.Allow,
+		Rules:   []policy.Rule{{Name: "secret", Effect: policy.Deny, Providers: []string{"secret"}}},
+	})
+	opts := Options{Policy: engine, Owners: []string{"philips"}}
+
+	e := echo.New()
+	e.GET("/v1/providers", ProvidersHandler(registry.client, opts))
+	e.GET("/v1/providers/:namespace", ProvidersHandler(registry.client, opts))
+	return e
+}
+
+// checkProviders checks the IDs of the providers listed at each target
+func checkProviders(t *testing.T, e *echo.Echo, tests []providersTest) {
+	t.Helper()
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			rec := httptest.NewRecorder()
+			e.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, tt.target, nil))
+			if rec.Code != tt.wantStatus {
+				t.Fatalf("Expected status code %d, got %d: %s", tt.wantStatus, rec.Code, rec.Body.String())
+			}
+			if tt.wantStatus != http.StatusOK {
+				return
+			}
+			var response models.ProviderListResponse
+			_ = json.Unmarshal(rec.Body.Bytes(), &response)
+			if len(response.Providers) != len(tt.wantIDs) {
+				t.Fatalf("Expected providers %v, got %+v", tt.wantIDs, response.Providers)
+			}
+			for i, id := range tt.wantIDs {
+				if response.Providers[i].ID != id {
+					t.Errorf("Expected provider %s, got %s", id, response.Providers[i].ID)
+				}
+			}
+			if got := response.Providers[0]; got.LatestVersion != "1.1.0" || got.Platforms != 2 {
+				t.Errorf("Expected latest version 1.1.0 with 2 platforms, got %+v", got)
+			}
+		})
+	}
+}
+
+type providersTest struct {
+	name       string
+	target     string
+	wantStatus int
+	wantIDs    []string
+}
+
+func TestProvidersHandler(t *testing.T) {
+	checkProviders(t, newProvidersServer(t, nil), []providersTest{
+		{name: "Namespace", target: "/v1/providers/philips", wantStatus: http.StatusOK, wantIDs: []string{"philips/hsdp"}},
+		{name: "All owners", target: "/v1/providers", wantStatus: http.StatusOK, wantIDs: []string{"philips/hsdp"}},
+		{name: "Search", target: "/v1/providers?q=hsdp", wantStatus: http.StatusOK, wantIDs: []string{"philips/hsdp"}},
+		{name: "Search with owner qualifiers", target: "/v1/providers?q=hsdp+user:someone-else+ORG:other+repo:x/y", wantStatus: http.StatusOK, wantIDs: []string{"philips/hsdp"}},
+		{name: "Other namespace", target: "/v1/providers/other", wantStatus: http.StatusNotFound},
+	})
+}
+
+func TestProvidersHandlerNamespaceMapping(t *testing.T) {
+	// The owner is mapped to a namespace which is also the name of another owner, so
+	// mapping twice lists the wrong organization
+	e := newProvidersServer(t, map[string]string{"acme": "philips", "philips": "philips-labs"})
+	checkProviders(t, e, []providersTest{
+		{name: "Namespace", target: "/v1/providers/acme", wantStatus: http.StatusOK, wantIDs: []string{"acme/hsdp"}},
+		{name: "All owners", target: "/v1/providers", wantStatus: http.StatusOK, wantIDs: []string{"acme/hsdp"}},
+		{name: "Search", target: "/v1/providers?q=hsdp", wantStatus: http.StatusOK, wantIDs: []string{"acme/hsdp"}},
+		{name: "Unhosted owner", target: "/v1/providers/philips", wantStatus: http.StatusNotFound},
+	})
+}
+
+func TestProviderHandlerVersionMetadata(t *testing.T) {
+	registry := newTestRegistry(t, []string{"1.0.0"}, []string{"linux_amd64"})
+	handler := ProviderHandler(registry.client, Options{})
+
+	rec := serve(handler, "/v1/providers/philips/hsdp/1.0.0", nil)
+	if rec.Code != http.StatusOK {
+		t.Fatalf("Expected status code %d, got %d: %s", http.StatusOK, rec.Code, rec.Body.String())
+	}
+	var response models.VersionMetadata
+	if err := json.Unmarshal(rec.Body.Bytes(), &response); err != nil {
+		t.Fatalf("Failed to unmarshal response: %v", err)
+	}
+	if response.ID != "philips/hsdp/1.0.0" || response.Tag != "v1.0.0" || response.Description != "Release 1.0.0" {
+		t.Errorf("Unexpected metadata %+v", response)
+	}
+	if response.PublishedAt == nil || response.PublishedAt.Year() != 2020 {
+		t.Errorf("Expected publish date in 2020, got %v", response.PublishedAt)
+	}
+	if !strings.HasSuffix(response.Source, "/releases/tag/v1.0.0") || len(response.Platforms) != 1 {
+		t.Errorf("Unexpected source or platforms %+v", response)
+	}
+	if len(response.SigningKeys) != 1 || response.SigningKeys[0] == "" {
+		t.Errorf("Expected the signing key ID, got %v", response.SigningKeys)
+	}
+
+	if rec = serve(handler, "/v1/providers/philips/hsdp/2.0.0", nil); rec.Code != http.StatusNotFound {
+		t.Errorf("Expected status code %d, got %d", http.StatusNotFound, rec.Code)
+	}
+}
+
+func TestProviderHandlerLatest(t *testing.T) {
+	registry := newTestRegistry(t, []string{"1.0.0", "1.2.0", "2.0.0-rc1"}, []string{"linux_amd64"})
+	handler := ProviderHandler(registry.client, Options{})
+
+	rec := serve(handler, "/v1/providers/philips/hsdp/latest", nil)
+	if rec.Code != http.StatusOK {
+		t.Fatalf("Expected status code %d, got %d: %s", http.StatusOK, rec.Code, rec.Body.String())
+	}
+	var metadata models.VersionMetadata
+	_ = json.Unmarshal(rec.Body.Bytes(), &metadata)
+	if metadata.Version != "1.2.0" {
+		t.Errorf("Expected latest version 1.2.0, got %s", metadata.Version)
+	}
+
+	rec = serve(handler, "/v1/providers/philips/hsdp/latest/download/linux/amd64", nil)
+	if rec.Code != http.StatusOK {
+		t.Fatalf("Expected status code %d, got %d: %s", http.StatusOK, rec.Code, rec.Body.String())
+	}
+	var download models.DownloadResponse
+	_ = json.Unmarshal(rec.Body.Bytes(), &download)
+	if download.Filename != "terraform-provider-hsdp_1.2.0_linux_amd64.zip" {
+		t.Errorf("Expected download of 1.2.0, got %s", download.Filename)
+	}
+
+	registry = newTestRegistry(t, []string{"2.0.0-rc1"}, []string{"linux_amd64"})
+	handler = ProviderHandler(registry.client, Options{})
+	if rec = serve(handler, "/v1/providers/philips/hsdp/latest", nil); rec.Code != http.StatusNotFound {
+		t.Errorf("Expected status code %d without releases, got %d", http.StatusNotFound, rec.Code)
+	}
+}
+
+func TestProviderHandlerChangelog(t *testing.T) {
+	registry := newTestRegistry(t, []string{"1.0.0", "1.1.0", "1.2.0", "1.3.0-beta1"}, []string{"linux_amd64"})
+	handler := ProviderHandler(registry.client, Options{})
+
+	tests := []struct {
+		name         string
+		query        string
+		wantStatus   int
+		wantVersions []string
+	}{
+		{name: "Since lock file version", query: "from=1.0.0", wantStatus: http.StatusOK, wantVersions: []string{"1.2.0", "1.1.0"}},
+		{name: "Between versions", query: "from=1.0.0&to=1.1.0", wantStatus: http.StatusOK, wantVersions: []string{"1.1.0"}},
+		{name: "All", query: "", wantStatus: http.StatusOK, wantVersions: []string{"1.2.0", "1.1.0", "1.0.0"}},
+		{name: "Invalid", query: "from=1.0.0,%3C2", wantStatus: http.StatusBadRequest},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			rec := serve(handler, "/v1/providers/philips/hsdp/changelog?"+tt.query, nil)
+			if rec.Code != tt.wantStatus {
+				t.Fatalf("Expected status code %d, got %d: %s", tt.wantStatus, rec.Code, rec.Body.String())
+			}
+			if tt.wantStatus != http.StatusOK {
+				return
+			}
+			var response models.ChangelogResponse
+			_ = json.Unmarshal(rec.Body.Bytes(), &response)
+			got := make([]string, 0, len(response.Releases))
+			for _, r := range response.Releases {
+				got = append(got, r.Version)
+			}
+			if strings.Join(got, ",") != strings.Join(tt.wantVersions, ",") {
+				t.Errorf("Expected versions %v, got %v", tt.wantVersions, got)
+			}
+		})
+	}
+
+	rec := serve(handler, "/v1/providers/philips/hsdp/changelog?from=1.1.0&format=markdown", nil)
+	want := "# philips/hsdp\n\n## [1.2.0](https://github.com/philips/terraform-provider-hsdp/releases/tag/v1.2.0) (2020-10-01)\n\nRelease 1.2.0\n"
+	if got := rec.Body.String(); got != want {
+		t.Errorf("Expected markdown\n%s\ngot\n%s", want, got)
+	}
+	if ct := rec.Header().Get(echo.HeaderContentType); ct != MIMETextMarkdown {
+		t.Errorf("Expected content type %s, got %s", MIMETextMarkdown, ct)
+	}
+}
+
+func TestDocsHandler(t *testing.T) {
+	registry := newTestRegistry(t, []string{"1.0.0"}, []string{"linux_amd64"})
+	content := func(path, body string) *github.RepositoryContent {
+		return &github.RepositoryContent{
+			Type:     github.String("file"),
+			Path:     github.String(path),
+			Encoding: github.String("base64"),
+			Content:  github.String(base64.StdEncoding.EncodeToString([]byte(body))),
+		}
+	}
+	registry.mux.HandleFunc("/api/v3/repos/philips/terraform-provider-hsdp/contents/", func(w http.ResponseWriter, r *http.Request) {
+		switch strings.TrimPrefix(r.URL.Path, "/api/v3/repos/philips/terraform-provider-hsdp/contents/") + "@" + r.URL.Query().Get("ref") {
+		case "docs@v1.0.0":
+			_ = json.NewEncoder(w).Encode([]*github.RepositoryContent{
+				{Type: github.String("file"), Path: github.String("docs/index.md")},
+				{Type: github.String("dir"), Path: github.String("docs/resources")},
+			})
+		case "docs/resources@v1.0.0":
+			_ = json.NewEncoder(w).Encode([]*github.RepositoryContent{
+				{Type: github.String("file"), Path: github.String("docs/resources/iam_group.md")},
+			})
+		case "docs/index.md@v1.0.0":
+			_ = json.NewEncoder(w).Encode(content("docs/index.md", "# HSDP provider\n"))
+		case "docs/resources/iam_group.md@v1.0.0":
+			_ = json.NewEncoder(w).Encode(content("docs/resources/iam_group.md", "---\npage_title: IAM group\n---\n# hsdp_iam_group\n"))
+		default:
+			w.WriteHeader(http.StatusNotFound)
+		}
+	})
+	opts := Options{Docs: docs.New(registry.client.Github)}
+	e := echo.New()
+	e.GET(DocsPath, DocsHandler(registry.client, opts))
+	e.GET(DocsPath+"/:category/:slug", DocsHandler(registry.client, opts))
+	get := func(target string) *httptest.ResponseRecorder {
+		rec := httptest.NewRecorder()
+		e.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, target, nil))
+		return rec
+	}
+
+	rec := get("/v2/providers/philips/hsdp/1.0.0/docs")
+	if rec.Code != http.StatusOK {
+		t.Fatalf("Expected status code %d, got %d: %s", http.StatusOK, rec.Code, rec.Body.String())
+	}
+	var list models.DocsListResponse
+	_ = json.Unmarshal(rec.Body.Bytes(), &list)
+	if len(list.Data) != 2 || list.Data[1].ID != "philips/hsdp/1.0.0/resources/iam_group" {
+		t.Errorf("Unexpected docs index %+v", list.Data)
+	}
+
+	rec = get("/v2/providers/philips/hsdp/latest/docs/resources/iam_group")
+	if rec.Code != http.StatusOK {
+		t.Fatalf("Expected status code %d, got %d: %s", http.StatusOK, rec.Code, rec.Body.String())
+	}
+	var doc models.DocResponse
+	_ = json.Unmarshal(rec.Body.Bytes(), &doc)
+	if doc.Data.Attributes.Title != "IAM group" || doc.Data.Attributes.Content != "# hsdp_iam_group\n" {
+		t.Errorf("Unexpected doc %+v", doc.Data.Attributes)
+	}
+
+	rec = get("/v2/providers/philips/hsdp/1.0.0/docs/resources/iam_group?format=html")
+	if rec.Code != http.StatusOK || !strings.Contains(rec.Body.String(), "<h1>hsdp_iam_group</h1>") {
+		t.Errorf("Expected rendered HTML, got %d: %s", rec.Code, rec.Body.String())
+	}
+	if rec = get("/v2/providers/philips/hsdp/1.0.0/docs/resources/unknown"); rec.Code != http.StatusNotFound {
+		t.Errorf("Expected status code %d, got %d", http.StatusNotFound, rec.Code)
+	}
+	if rec = get("/v2/providers/philips/hsdp/2.0.0/docs"); rec.Code != http.StatusNotFound {
+		t.Errorf("Expected status code %d, got %d", http.StatusNotFound, rec.Code)
+	}
+}
+
+func TestProviderHandlerPlatforms(t *testing.T) {
+	registry := newTestRegistry(t, []string{"1.0.0", "1.1.0"}, []string{"linux_amd64", "darwin_arm64"})
+	// 1.0.0 was released without its darwin_arm64 build
+	release := registry.releases[0]
+	assets := release.Assets[:0]
+	for _, a := range release.Assets {
+		if !strings.Contains(a.GetName(), "darwin_arm64") {
+			assets = append(assets, a)
+		}
+	}
+	release.Assets = assets
+	handler := ProviderHandler(registry.client, Options{})
+
+	rec := serve(handler, "/v1/providers/philips/hsdp/platforms", nil)
+	if rec.Code != http.StatusOK {
+		t.Fatalf("Expected status code %d, got %d: %s", http.StatusOK, rec.Code, rec.Body.String())
+	}
+	var response models.PlatformMatrix
+	_ = json.Unmarshal(rec.Body.Bytes(), &response)
+	if strings.Join(response.Platforms, ",") != "darwin_arm64,linux_amd64" {
+		t.Errorf("Expected all platforms, got %v", response.Platforms)
+	}
+	if len(response.Versions) != 2 {
+		t.Fatalf("Expected 2 versions, got %+v", response.Versions)
+	}
+	if v := response.Versions[0]; v.Version != "1.1.0" || len(v.Missing) != 0 {
+		t.Errorf("Expected complete 1.1.0, got %+v", v)
+	}
+	if v := response.Versions[1]; v.Version != "1.0.0" || strings.Join(v.Missing, ",") != "darwin_arm64" {
+		t.Errorf("Expected 1.0.0 missing darwin_arm64, got %+v", v)
+	}
+}
+
+func TestProviderHandlerMirror(t *testing.T) {
+	registry := newTestRegistry(t, []string{"1.0.0"}, []string{"linux_amd64"})
+	store, err := storage.NewLocal(t.TempDir())
+	if err != nil {
+		t.Fatalf("NewLocal() error = %v", err)
+	}
+	m := mirror.New(store, nil, nil)
+
+	e := echo.New()
+	e.GET("/v1/providers/:namespace/:type/*", ProviderHandler(registry.client, Options{Mirror: m}))
+	e.GET(mirror.PathPrefix+":namespace/:type/:version/:filename", MirrorHandler(registry.client, Options{Mirror: m}))
+	get := func(target string) *httptest.ResponseRecorder {
+		rec := httptest.NewRecorder()
+		e.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, target, nil))
+		return rec
+	}
+	zipName := "terraform-provider-hsdp_1.0.0_linux_amd64.zip"
+
+	rec := get("/v1/providers/philips/hsdp/1.0.0/download/linux/amd64")
+	if rec.Code != http.StatusOK {
+		t.Fatalf("Expected status code %d, got %d: %s", http.StatusOK, rec.Code, rec.Body.String())
+	}
+	var response models.DownloadResponse
+	_ = json.Unmarshal(rec.Body.Bytes(), &response)
+	if !strings.HasPrefix(response.DownloadURL, mirror.PathPrefix) {
+		t.Fatalf("Expected mirror download URL, got %s", response.DownloadURL)
+	}
+
+	rec = get(response.DownloadURL)
+	if rec.Code != http.StatusOK {
+		t.Fatalf("Expected status code %d, got %d: %s", http.StatusOK, rec.Code, rec.Body.String())
+	}
+	if !bytes.Equal(rec.Body.Bytes(), registry.assets[zipName]) {
+		t.Error("Mirrored zip does not match the release asset")
+	}
+	if rec = get(strings.Split(response.DownloadURL, "?")[0]); rec.Code != http.StatusForbidden {
+		t.Errorf("Expected status code %d for unsigned URL, got %d", http.StatusForbidden, rec.Code)
+	}
+
+	// Deleted releases keep being served from the mirror
+	registry.releases = nil
+	rec = get("/v1/providers/philips/hsdp/1.0.0/download/linux/amd64")
+	if rec.Code != http.StatusOK {
+		t.Fatalf("Expected status code %d, got %d: %s", http.StatusOK, rec.Code, rec.Body.String())
+	}
+	_ = json.Unmarshal(rec.Body.Bytes(), &response)
+	want := fmt.Sprintf("%x", sha256.Sum256(registry.assets[zipName]))
+	if response.Shasum != want {
+		t.Errorf("Expected shasum %s, got %s", want, response.Shasum)
+	}
+	if rec = get(response.ShasumsURL); rec.Code != http.StatusOK {
+		t.Errorf("Expected status code %d for shasums, got %d", http.StatusOK, rec.Code)
+	}
+}
+
+func TestProviderHandlerVerify(t *testing.T) {
+	registry := newTestRegistry(t, []string{"1.0.0"}, []string{"linux_amd64", "darwin_arm64"})
+	registry.assets["terraform-provider-hsdp_1.0.0_darwin_arm64.zip"] = []byte("tampered")
+	verifier, err := verify.New(verify.OnRequest, 0, nil)
+	if err != nil {
+		t.Fatalf("verify.New() error = %v", err)
+	}
+	handler := ProviderHandler(registry.client, Options{Verifier: verifier})
+
+	if rec := serve(handler, "/v1/providers/philips/hsdp/1.0.0/download/linux/amd64", nil); rec.Code != http.StatusOK {
+		t.Errorf("Expected status code %d, got %d: %s", http.StatusOK, rec.Code, rec.Body.String())
+	}
+	if rec := serve(handler, "/v1/providers/philips/hsdp/1.0.0/download/darwin/arm64", nil); rec.Code != http.StatusNotFound {
+		t.Errorf("Expected status code %d, got %d: %s", http.StatusNotFound, rec.Code, rec.Body.String())
+	}
+
+	rec := serve(handler, "/v1/providers/philips/hsdp/versions", nil)
+	var response models.VersionResponse
+	_ = json.Unmarshal(rec.Body.Bytes(), &response)
+	if len(response.Versions) != 1 {
+		t.Fatalf("Expected 1 version, got %d", len(response.Versions))
+	}
+	platforms := response.Versions[0].Platforms
+	if len(platforms) != 1 || platforms[0].Os != "linux" {
+		t.Errorf("Expected only linux/amd64 to be available, got %+v", platforms)
+	}
+}
+
+func TestProviderHandlerHashes(t *testing.T) {
+	registry := newTestRegistry(t, []string{"1.0.0"}, []string{"linux_amd64", "darwin_arm64"})
+	registry.assets["terraform-provider-hsdp_1.0.0_darwin_arm64.zip"] = testZip(t, "tampered", "darwin_arm64")
+	handler := ProviderHandler(registry.client, Options{Hasher: hashes.New()})
+
+	rec := serve(handler, "/v1/providers/philips/hsdp/1.0.0/hashes", nil)
+	if rec.Code != http.StatusOK {
+		t.Fatalf("Expected status code %d, got %d: %s", http.StatusOK, rec.Code, rec.Body.String())
+	}
+	var response models.HashesResponse
+	if err := json.Unmarshal(rec.Body.Bytes(), &response); err != nil {
+		t.Fatalf("Failed to unmarshal response: %v", err)
+	}
+	if len(response.Hashes) != 1 {
+		t.Fatalf("Expected hashes for linux_amd64 only, got %v", response.Hashes)
+	}
+	zipName := "terraform-provider-hsdp_1.0.0_linux_amd64.zip"
+	want, _ := hashes.Compute(bytes.NewReader(registry.assets[zipName]))
+	got := response.Hashes["linux_amd64"]
+	if len(got) != 2 || got[0] != want.H1 || got[1] != want.ZH {
+		t.Errorf("Expected hashes %v, got %v", []string{want.H1, want.ZH}, got)
+	}
+
+	rec = serve(handler, "/v1/providers/philips/hsdp/1.0.0/hashes?platforms=windows_amd64", nil)
+	response = models.HashesResponse{}
+	_ = json.Unmarshal(rec.Body.Bytes(), &response)
+	if rec.Code != http.StatusOK || len(response.Hashes) != 0 {
+		t.Errorf("Expected no hashes for unknown platform, got %d: %s", rec.Code, rec.Body.String())
+	}
+	if rec = serve(handler, "/v1/providers/philips/hsdp/2.0.0/hashes", nil); rec.Code != http.StatusNotFound {
+		t.Errorf("Expected status code %d, got %d", http.StatusNotFound, rec.Code)
+	}
+}
+
+func TestProviderHandlerHashesConcurrency(t *testing.T) {
+	platforms := []string{"linux_amd64", "linux_arm64", "darwin_amd64", "darwin_arm64", "windows_amd64", "windows_386", "freebsd_amd64", "openbsd_amd64"}
+	registry := newTestRegistry(t, []string{"1.0.0"}, platforms)
+	var running, most atomic.Int32
+	registry.onDownload = func() {
+		n := running.Add(1)
+		defer running.Add(-1)
+		for m := most.Load(); n > m && !most.CompareAndSwap(m, n); m = most.Load() {
+		}
+		time.Sleep(20 * time.Millisecond)
+	}
+	// Without a Hasher the hashes are computed on every request
+	handler := ProviderHandler(registry.client, Options{})
+
+	rec := serve(handler, "/v1/providers/philips/hsdp/1.0.0/hashes", nil)
+	if rec.Code != http.StatusOK {
+		t.Fatalf("Expected status code %d, got %d: %s", http.StatusOK, rec.Code, rec.Body.String())
+	}
+	var response models.HashesResponse
+	_ = json.Unmarshal(rec.Body.Bytes(), &response)
+	if len(response.Hashes) != len(platforms) {
+		t.Errorf("Expected hashes for %d platforms, got %v", len(platforms), response.Hashes)
+	}
+	if n := most.Load(); n < 2 || n > maxHashes {
+		t.Errorf("Expected between 2 and %d concurrent downloads, got %d", maxHashes, n)
+	}
+}
+
+func TestNetworkMirrorHandler(t *testing.T) {
+	registry := newTestRegistry(t, []string{"1.0.0", "2.0.0"}, []string{"linux_amd64"})
+	engine, err := policy.NewEngine(policy.Policy{
+		Default: policy.Allow,
+		Rules: []policy.Rule{
+			{Name: "no-v2", Effect: policy.Deny, Versions: ">= 2.0.0"},
+		},
+	})
+	if err != nil {
+		t.Fatalf("NewEngine() error = %v", err)
+	}
+	e := echo.New()
+	e.GET(NetworkMirrorPath+":hostname/:namespace/:type/:file", NetworkMirrorHandler(registry.client, Options{
+		Policy: engine,
+		Hasher: hashes.New(),
+	}))
+	get := func(target string) *httptest.ResponseRecorder {
+		rec := httptest.NewRecorder()
+		e.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, target, nil))
+		return rec
+	}
+
+	rec := get("/v1/network-mirror/registry.example.com/philips/hsdp/index.json")
+	if rec.Code != http.StatusOK {
+		t.Fatalf("Expected status code %d, got %d: %s", http.StatusOK, rec.Code, rec.Body.String())
+	}
+	var index models.NetworkMirrorIndex
+	_ = json.Unmarshal(rec.Body.Bytes(), &index)
+	if _, ok := index.Versions["1.0.0"]; !ok || len(index.Versions) != 1 {
+		t.Errorf("Expected only version 1.0.0, got %v", index.Versions)
+	}
+
+	rec = get("/v1/network-mirror/registry.example.com/philips/hsdp/1.0.0.json")
+	if rec.Code != http.StatusOK {
+		t.Fatalf("Expected status code %d, got %d: %s", http.StatusOK, rec.Code, rec.Body.String())
+	}
+	var version models.NetworkMirrorVersion
+	_ = json.Unmarshal(rec.Body.Bytes(), &version)
+	archive, ok := version.Archives["linux_amd64"]
+	if !ok {
+		t.Fatalf("Expected linux_amd64 archive, got %v", version.Archives)
+	}
+	zipName := "terraform-provider-hsdp_1.0.0_linux_amd64.zip"
+	want, _ := hashes.Compute(bytes.NewReader(registry.assets[zipName]))
+	if len(archive.Hashes) != 1 || archive.Hashes[0] != want.H1 {
+		t.Errorf("Expected hashes [%s], got %v", want.H1, archive.Hashes)
+	}
+	if !strings.HasSuffix(archive.URL, "/"+zipName) {
+		t.Errorf("Expected URL of %s, got %s", zipName, archive.URL)
+	}
+
+	if rec = get("/v1/network-mirror/registry.example.com/philips/hsdp/2.0.0.json"); rec.Code != http.StatusForbidden {
+		t.Errorf("Expected status code %d, got %d", http.StatusForbidden, rec.Code)
+	}
+	if rec = get("/v1/network-mirror/registry.example.com/philips/hsdp/0.9.0.json"); rec.Code != http.StatusNotFound {
+		t.Errorf("Expected status code %d, got %d", http.StatusNotFound, rec.Code)
+	}
+}
+
+func TestLockHandler(t *testing.T) {
+	registry := newTestRegistry(t, []string{"1.2.0", "1.3.0", "2.0.0"}, []string{"linux_amd64", "darwin_arm64"})
+	e := echo.New()
+	e.POST(LockPath, LockHandler(registry.client, Options{Hasher: hashes.New()}))
+	post := func(body string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest(http.MethodPost, LockPath, strings.NewReader(body))
+		req.Header.Set(echo.HeaderContentType, echo.MIMEApplicationJSON)
+		req.Host = "registry.example.com"
+		rec := httptest.NewRecorder()
+		e.ServeHTTP(rec, req)
+		return rec
+	}
+
+	rec := post(`{"providers":[{"source":"philips/hsdp","version":"~> 1.2"}],"platforms":["linux_amd64","darwin_arm64"]}`)
+	if rec.Code != http.StatusOK {
+		t.Fatalf("Expected status code %d, got %d: %s", http.StatusOK, rec.Code, rec.Body.String())
+	}
+	var want strings.Builder
+	want.WriteString(lockFileHeader)
+	want.WriteString("\nprovider \"registry.example.com/philips/hsdp\" {\n")
+	want.WriteString("  version     = \"1.3.0\"\n")
+	want.WriteString("  constraints = \"~> 1.2\"\n")
+	want.WriteString("  hashes = [\n")
+	var hashList []string
+	for _, platform := range []string{"linux_amd64", "darwin_arm64"} {
+		h, _ := hashes.Compute(bytes.NewReader(registry.assets["terraform-provider-hsdp_1.3.0_"+platform+".zip"]))
+		hashList = append(hashList, h.H1, h.ZH)
+	}
+	sort.Strings(hashList)
+	for _, h := range hashList {
+		fmt.Fprintf(&want, "    %q,\n", h)
+	}
+	want.WriteString("  ]\n}\n")
+	if got := rec.Body.String(); got != want.String() {
+		t.Errorf("Expected lock file\n%s\ngot\n%s", want.String(), got)
+	}
+
+	tests := []struct {
+		name string
+		body string
+		want int
+	}{
+		{"no platforms", `{"providers":[{"source":"philips/hsdp"}]}`, http.StatusBadRequest},
+		{"invalid source", `{"providers":[{"source":"hsdp"}],"platforms":["linux_amd64"]}`, http.StatusBadRequest},
+		{"invalid constraint", `{"providers":[{"source":"philips/hsdp","version":"~> x"}],"platforms":["linux_amd64"]}`, http.StatusBadRequest},
+		{"no match", `{"providers":[{"source":"philips/hsdp","version":"> 3.0"}],"platforms":["linux_amd64"]}`, http.StatusNotFound},
+		{"unknown platform", `{"providers":[{"source":"philips/hsdp"}],"platforms":["windows_amd64"]}`, http.StatusNotFound},
+		{"duplicate", `{"providers":[{"source":"philips/hsdp"},{"source":"example.com/philips/hsdp"}],"platforms":["linux_amd64"]}`, http.StatusBadRequest},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if rec := post(tt.body); rec.Code != tt.want {
+				t.Errorf("Expected status code %d, got %d: %s", tt.want, rec.Code, rec.Body.String())
+			}
+		})
+	}
+}
@@ -0,0 +1,187 @@
+/*
+Copyright © 2020 Andy Lo-A-Foe
+
+Permission is hereby granted, free of charge, to any person obtaining a copy
+of this software and associated documentation files (the "Software"), to deal
+in the Software without restriction, including without limitation the rights
+to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+copies of the Software, and to permit persons to whom the Software is
+furnished to do so, subject to the following conditions:
+
+The above copyright notice and this permission notice shall be included in
+all copies or substantial portions of the Software.
+
+THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+THE SOFTWARE.
+*/
+package handler
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"slices"
+	"strings"
+	"sync"
+
+	"terraform-registry/internal/client"
+	"terraform-registry/internal/download"
+	"terraform-registry/internal/hashes"
+	"terraform-registry/internal/logging"
+	"terraform-registry/internal/mirror"
+	"terraform-registry/internal/models"
+	"terraform-registry/internal/parser"
+	"terraform-registry/internal/verify"
+
+	"github.com/google/go-github/v32/github"
+	"github.com/labstack/echo/v4"
+	"golang.org/x/sync/errgroup"
+)
+
+// platformZip is a platform zip of a release along with its lock file hashes
+type platformZip struct {
+	asset  *github.ReleaseAsset
+	hashes *hashes.Hashes
+}
+
+// versionHashes answers the hashes extension endpoint, listing the h1: and zh: hashes
+// of the platforms of a version. The platforms query parameter limits the platforms.
+func versionHashes(c echo.Context, client *client.Client, opts Options, repos []*github.RepositoryRelease, version string) error {
+	namespace := c.Param("namespace")
+	typeParam := c.Param("type")
+	release := findRelease(repos, version)
+	if release == nil {
+		return c.JSON(http.StatusNotFound, &models.ErrorResponse{
+			Status:  http.StatusNotFound,
+			Message: fmt.Sprintf("cannot find version: %s", version),
+		})
+	}
+	var only []string
+	if platforms := c.QueryParam("platforms"); platforms != "" {
+		only = strings.Split(platforms, ",")
+	}
+	zips, err := releaseHashes(c, client, opts, namespace, typeParam, release, version, only)
+	if err != nil {
+		status := upstreamStatus(err, http.StatusBadGateway)
+		return c.JSON(status, &models.ErrorResponse{
+			Status:  status,
+			Message: fmt.Sprintf("failed hashing %s/%s %s: %v", namespace, typeParam, version, err),
+		})
+	}
+	response := &models.HashesResponse{
+		ID:      namespace + "/" + typeParam,
+		Version: version,
+		Hashes:  make(map[string][]string, len(zips)),
+	}
+	for platform, zip := range zips {
+		response.Hashes[platform] = []string{zip.hashes.H1, zip.hashes.ZH}
+	}
+	return c.JSON(http.StatusOK, response)
+}
+
+// maxHashes bounds the zips of a release which are downloaded and hashed at once
+const maxHashes = 4
+
+// releaseHashes computes the hashes of the platform zips of a release, keyed by
+// os_arch, hashing up to maxHashes zips at once. When only is set just those platforms are hashed. Platforms whose zip does
+// not match SHA256SUMS are left out and marked with the Verifier.
+func releaseHashes(c echo.Context, client *client.Client, opts Options, namespace, typeParam string, release *github.RepositoryRelease, version string, only []string) (map[string]platformZip, error) {
+	provider := "terraform-provider-" + typeParam
+	c.Set("namespace", namespace)
+	c.Set("provider", provider)
+
+	shasumFilename := fmt.Sprintf("%s_%s_SHA256SUMS", provider, version)
+	assets := make(map[string]*github.ReleaseAsset, len(release.Assets))
+	for _, a := range release.Assets {
+		assets[*a.Name] = a
+	}
+	if assets[shasumFilename] == nil {
+		return nil, fmt.Errorf("cannot find asset: %s", shasumFilename)
+	}
+	shasums, err := readAsset(c, client, opts, namespace, typeParam, version, assets[shasumFilename])
+	if err != nil {
+		return nil, err
+	}
+
+	var mu sync.Mutex
+	zips := make(map[string]platformZip)
+	var g errgroup.Group
+	g.SetLimit(maxHashes)
+	for _, p := range parser.CollectPlatforms(release.Assets) {
+		key := p.Os + "_" + p.Arch
+		if len(only) > 0 && !slices.Contains(only, key) {
+			continue
+		}
+		platform := verify.Platform{
+			Namespace: namespace,
+			Type:      typeParam,
+			Version:   version,
+			Os:        p.Os,
+			Arch:      p.Arch,
+		}
+		if opts.Verifier.Status(platform) == verify.Mismatch {
+			continue
+		}
+		filename := fmt.Sprintf("%s_%s_%s.zip", provider, version, key)
+		asset := assets[filename]
+		if asset == nil {
+			continue
+		}
+		shasum, err := download.FindShasum(filename, bytes.NewReader(shasums))
+		if err != nil {
+			return nil, err
+		}
+		artifact := mirror.Artifact{Namespace: namespace, Type: typeParam, Version: version, Filename: filename}
+		g.Go(func() error {
+			h, err := opts.Hasher.Get(namespace+"/"+typeParam+"/"+version+"/"+key, shasum, func() (io.ReadCloser, error) {
+				return openAsset(c, client, opts, artifact, asset)
+			})
+			if errors.Is(err, download.ErrChecksumMismatch) {
+				opts.Verifier.Mark(platform, verify.Mismatch)
+				logging.FromContext(c.Request().Context()).Error("zip does not match its shasum", "platform", platform.String())
+				return nil
+			}
+			if err != nil {
+				return err
+			}
+			mu.Lock()
+			zips[key] = platformZip{asset: asset, hashes: h}
+			mu.Unlock()
+			return nil
+		})
+	}
+	if err := g.Wait(); err != nil {
+		return nil, err
+	}
+	return zips, nil
+}
+
+// openAsset opens a release asset from the mirror when it is mirrored, from GitHub otherwise
+func openAsset(c echo.Context, client *client.Client, opts Options, artifact mirror.Artifact, asset *github.ReleaseAsset) (io.ReadCloser, error) {
+	if opts.Mirror != nil && opts.Mirror.Has(artifact) {
+		return opts.Mirror.Open(artifact)
+	}
+	url, err := client.GetURL(c, asset)
+	if err != nil {
+		return nil, err
+	}
+	return download.Open(c.Request().Context(), opts.HTTPClient, url)
+}
+
+// readAsset reads a small release asset, such as SHA256SUMS, into memory
+func readAsset(c echo.Context, client *client.Client, opts Options, namespace, typeParam, version string, asset *github.ReleaseAsset) ([]byte, error) {
+	artifact := mirror.Artifact{Namespace: namespace, Type: typeParam, Version: version, Filename: *asset.Name}
+	body, err := openAsset(c, client, opts, artifact, asset)
+	if err != nil {
+		return nil, err
+	}
+	defer func() { _ = body.Close() }()
+	return io.ReadAll(body)
+}
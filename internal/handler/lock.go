@@ -0,0 +1,202 @@
+/*
+Copyright © 2020 Andy Lo-A-Foe
+
+Permission is hereby granted, free of charge, to any person obtaining a copy
+of this software and associated documentation files (the "Software"), to deal
+in the Software without restriction, including without limitation the rights
+to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+copies of the Software, and to permit persons to whom the Software is
+furnished to do so, subject to the following conditions:
+
+The above copyright notice and this permission notice shall be included in
+all copies or substantial portions of the Software.
+
+THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+THE SOFTWARE.
+*/
+package handler
+
+import (
+	"fmt"
+	"net/http"
+	"slices"
+	"sort"
+	"strings"
+
+	"terraform-registry/internal/auth"
+	"terraform-registry/internal/client"
+	"terraform-registry/internal/logging"
+	"terraform-registry/internal/models"
+	"terraform-registry/internal/parser"
+	"terraform-registry/internal/policy"
+
+	"github.com/labstack/echo/v4"
+)
+
+// LockPath is the path of the lock file endpoint
+const LockPath = "/v1/lock"
+
+const lockFileHeader = `# This file is maintained automatically by "terraform init".
+# Manual edits may be lost in future updates.
+`
+
+// lockEntry is a provider block of .terraform.lock.hcl
+type lockEntry struct {
+	source      string
+	version     string
+	constraints string
+	hashes      []string
+}
+
+// LockHandler returns the handler generating .terraform.lock.hcl entries for providers,
+// resolving their version constraints and hashing the zips of the requested platforms
+func LockHandler(client *client.Client, opts Options) echo.HandlerFunc {
+	return func(c echo.Context) error {
+		var request models.LockRequest
+		if err := c.Bind(&request); err != nil {
+			return c.JSON(http.StatusBadRequest, &models.ErrorResponse{
+				Status:  http.StatusBadRequest,
+				Message: fmt.Sprintf("invalid lock request: %v", err),
+			})
+		}
+		if len(request.Providers) == 0 || len(request.Platforms) == 0 {
+			return c.JSON(http.StatusBadRequest, &models.ErrorResponse{
+				Status:  http.StatusBadRequest,
+				Message: "providers and platforms are required",
+			})
+		}
+
+		entries := make([]lockEntry, 0, len(request.Providers))
+		seen := make(map[string]bool)
+		for _, provider := range request.Providers {
+			entry, errResp := lockProvider(c, client, opts, provider, request.Platforms)
+			if errResp == nil && seen[entry.source] {
+				errResp = &models.ErrorResponse{
+					Status:  http.StatusBadRequest,
+					Message: fmt.Sprintf("duplicate provider %s", provider.Source),
+				}
+			}
+			if errResp != nil {
+				return c.JSON(errResp.Status, errResp)
+			}
+			seen[entry.source] = true
+			entries = append(entries, *entry)
+		}
+		sort.Slice(entries, func(i, j int) bool {
+			return entries[i].source < entries[j].source
+		})
+		return c.String(http.StatusOK, lockFile(entries))
+	}
+}
+
+// lockProvider resolves the newest version of a provider matching its constraint and
+// builds its lock file entry from the hashes of the platform zips
+func lockProvider(c echo.Context, client *client.Client, opts Options, provider models.LockProvider, platforms []string) (*lockEntry, *models.ErrorResponse) {
+	parts := strings.Split(provider.Source, "/")
+	if len(parts) == 3 {
+		parts = parts[1:]
+	}
+	if len(parts) != 2 || parts[0] == "" || parts[1] == "" {
+		return nil, &models.ErrorResponse{
+			Status:  http.StatusBadRequest,
+			Message: fmt.Sprintf("invalid provider source %q", provider.Source),
+		}
+	}
+	namespace, typeParam := parts[0], parts[1]
+
+	request := policy.Request{
+		Identity:  auth.GetIdentity(c),
+		Namespace: namespace,
+		Type:      typeParam,
+	}
+	if decision := opts.Policy.Evaluate(request); !decision.Allowed {
+		logging.FromContext(c.Request().Context()).Warn("denied by policy", "reason", decision.Reason)
+		return nil, &models.ErrorResponse{
+			Status:  http.StatusForbidden,
+			Message: decision.Reason,
+		}
+	}
+	repos, resp, err := client.ListReleases(c.Request().Context(), namespace, "terraform-provider-"+typeParam)
+	if err != nil {
+		status := upstreamStatus(err, http.StatusBadGateway)
+		if resp != nil && resp.StatusCode == http.StatusNotFound {
+			status = http.StatusNotFound
+		}
+		return nil, &models.ErrorResponse{
+			Status:  status,
+			Message: err.Error(),
+		}
+	}
+	versions, err := parser.ParseVersions(repos)
+	if err != nil {
+		return nil, &models.ErrorResponse{
+			Status:  http.StatusBadGateway,
+			Message: err.Error(),
+		}
+	}
+	allowed, _ := filterVersions(opts.Policy, request, versions)
+	matches, err := parser.MatchVersions(availablePlatforms(opts.Verifier, namespace, typeParam, allowed), provider.Version)
+	if err != nil {
+		return nil, &models.ErrorResponse{
+			Status:  http.StatusBadRequest,
+			Message: err.Error(),
+		}
+	}
+	if len(matches) == 0 {
+		return nil, &models.ErrorResponse{
+			Status:  http.StatusNotFound,
+			Message: fmt.Sprintf("no version of %s/%s matches %q", namespace, typeParam, provider.Version),
+		}
+	}
+	version := matches[0].Version
+
+	zips, err := releaseHashes(c, client, opts, namespace, typeParam, findRelease(repos, version), version, platforms)
+	if err != nil {
+		return nil, &models.ErrorResponse{
+			Status:  upstreamStatus(err, http.StatusBadGateway),
+			Message: fmt.Sprintf("failed hashing %s/%s %s: %v", namespace, typeParam, version, err),
+		}
+	}
+	entry := &lockEntry{
+		source:      strings.ToLower(c.Request().Host + "/" + namespace + "/" + typeParam),
+		version:     version,
+		constraints: provider.Version,
+	}
+	for _, platform := range platforms {
+		zip, ok := zips[platform]
+		if !ok {
+			return nil, &models.ErrorResponse{
+				Status:  http.StatusNotFound,
+				Message: fmt.Sprintf("%s/%s %s is not available for %s", namespace, typeParam, version, platform),
+			}
+		}
+		entry.hashes = append(entry.hashes, zip.hashes.H1, zip.hashes.ZH)
+	}
+	sort.Strings(entry.hashes)
+	entry.hashes = slices.Compact(entry.hashes)
+	return entry, nil
+}
+
+// lockFile renders entries in the format of .terraform.lock.hcl
+func lockFile(entries []lockEntry) string {
+	var b strings.Builder
+	b.WriteString(lockFileHeader)
+	for _, e := range entries {
+		fmt.Fprintf(&b, "\nprovider %q {\n", e.source)
+		fmt.Fprintf(&b, "  version     = %q\n", e.version)
+		if e.constraints != "" {
+			fmt.Fprintf(&b, "  constraints = %q\n", e.constraints)
+		}
+		b.WriteString("  hashes = [\n")
+		for _, h := range e.hashes {
+			fmt.Fprintf(&b, "    %q,\n", h)
+		}
+		b.WriteString("  ]\n}\n")
+	}
+	return b.String()
+}
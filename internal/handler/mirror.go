@@ -0,0 +1,222 @@
+/*
+Copyright © 2020 Andy Lo-A-Foe
+
+Permission is hereby granted, free of charge, to any person obtaining a copy
+of this software and associated documentation files (the "Software"), to deal
+in the Software without restriction, including without limitation the rights
+to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+copies of the Software, and to permit persons to whom the Software is
+furnished to do so, subject to the following conditions:
+
+The above copyright notice and this permission notice shall be included in
+all copies or substantial portions of the Software.
+
+THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+THE SOFTWARE.
+*/
+package handler
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"strings"
+
+	"terraform-registry/internal/client"
+	"terraform-registry/internal/crypto"
+	"terraform-registry/internal/download"
+	"terraform-registry/internal/logging"
+	"terraform-registry/internal/mirror"
+	"terraform-registry/internal/models"
+	"terraform-registry/internal/verify"
+
+	"github.com/google/go-github/v32/github"
+	"github.com/labstack/echo/v4"
+)
+
+// MirrorHandler serves mirrored release artifacts through the signed URLs handed out
+// by the download endpoint, fetching them from GitHub when not mirrored yet
+func MirrorHandler(client *client.Client, opts Options) echo.HandlerFunc {
+	m := opts.Mirror
+	return func(c echo.Context) error {
+		artifact := mirror.Artifact{
+			Namespace: c.Param("namespace"),
+			Type:      c.Param("type"),
+			Version:   c.Param("version"),
+			Filename:  c.Param("filename"),
+		}
+		if err := m.Verify(artifact, c.QueryParam("expires"), c.QueryParam("signature")); err != nil {
+			return c.JSON(http.StatusForbidden, &models.ErrorResponse{
+				Status:  http.StatusForbidden,
+				Message: err.Error(),
+			})
+		}
+		if !m.Has(artifact) {
+			if err := pullThrough(c, client, opts, artifact); err != nil {
+				status := upstreamStatus(err, http.StatusBadGateway)
+				return c.JSON(status, &models.ErrorResponse{
+					Status:  status,
+					Message: fmt.Sprintf("failed mirroring %s: %v", artifact.Filename, err),
+				})
+			}
+		}
+		body, err := m.Open(artifact)
+		if err != nil {
+			return c.JSON(http.StatusNotFound, &models.ErrorResponse{
+				Status:  http.StatusNotFound,
+				Message: fmt.Sprintf("cannot find %s", artifact.Filename),
+			})
+		}
+		defer func() { _ = body.Close() }()
+
+		return c.Stream(http.StatusOK, contentType(artifact.Filename), body)
+	}
+}
+
+// releaseArtifacts returns the zip, SHA256SUMS, signature and signing key artifacts
+// needed to download a provider, in that order
+func releaseArtifacts(namespace, typeParam string, action map[string]string) []mirror.Artifact {
+	provider := "terraform-provider-" + typeParam
+	version := action["version"]
+	filenames := []string{
+		fmt.Sprintf("%s_%s_%s_%s.zip", provider, version, action["os"], action["arch"]),
+		fmt.Sprintf("%s_%s_SHA256SUMS", provider, version),
+		fmt.Sprintf("%s_%s_SHA256SUMS.sig", provider, version),
+		"signkey.asc",
+	}
+	artifacts := make([]mirror.Artifact, 0, len(filenames))
+	for _, filename := range filenames {
+		artifacts = append(artifacts, mirror.Artifact{
+			Namespace: namespace,
+			Type:      typeParam,
+			Version:   version,
+			Filename:  filename,
+		})
+	}
+	return artifacts
+}
+
+// mirroredDownload builds the download response from the mirror alone, failing when
+// any of the release artifacts is not mirrored
+func mirroredDownload(m *mirror.Mirror, namespace, typeParam string, action map[string]string) (*models.DownloadResponse, error) {
+	artifacts := releaseArtifacts(namespace, typeParam, action)
+	if !m.Has(artifacts...) {
+		return nil, os.ErrNotExist
+	}
+	sums, err := m.Open(artifacts[1])
+	if err != nil {
+		return nil, err
+	}
+	defer func() { _ = sums.Close() }()
+	shasum, err := download.FindShasum(artifacts[0].Filename, sums)
+	if err != nil {
+		return nil, err
+	}
+	key, err := m.Open(artifacts[3])
+	if err != nil {
+		return nil, err
+	}
+	defer func() { _ = key.Close() }()
+	data, err := io.ReadAll(key)
+	if err != nil {
+		return nil, err
+	}
+	pgpPublicKey, pgpPublicKeyID, err := crypto.ParsePublicKey(data)
+	if err != nil {
+		return nil, err
+	}
+	return newDownloadResponse(action, artifacts[0].Filename, m.URL(artifacts[0]), m.URL(artifacts[1]),
+		m.URL(artifacts[2]), shasum, pgpPublicKey, pgpPublicKeyID), nil
+}
+
+// mirrorRelease stores the release artifacts found at urls. The zip is fetched in
+// the background and verified against shasum, the mirror handler waits for it.
+func mirrorRelease(c echo.Context, opts Options, platform verify.Platform, artifacts []mirror.Artifact, urls []string, shasum string) error {
+	ctx := c.Request().Context()
+	for i := 1; i < len(artifacts); i++ {
+		if err := opts.Mirror.Fetch(ctx, artifacts[i], urls[i], ""); err != nil {
+			return err
+		}
+	}
+	logger := logging.FromContext(ctx)
+	background := context.WithoutCancel(ctx)
+	opts.Mirror.Go(func() {
+		err := opts.Mirror.Fetch(background, artifacts[0], urls[0], shasum)
+		switch {
+		case errors.Is(err, download.ErrChecksumMismatch):
+			opts.Verifier.Mark(platform, verify.Mismatch)
+			logger.Error("mirrored zip does not match its shasum", "platform", platform.String())
+		case err != nil:
+			logger.Error("mirroring zip", "error", err)
+		default:
+			opts.Verifier.Mark(platform, verify.Verified)
+		}
+	})
+	return nil
+}
+
+// pullThrough fetches an artifact which is not mirrored yet from its GitHub release
+func pullThrough(c echo.Context, client *client.Client, opts Options, artifact mirror.Artifact) error {
+	provider := "terraform-provider-" + artifact.Type
+	repos, _, err := client.ListReleases(c.Request().Context(), artifact.Namespace, provider)
+	if err != nil {
+		return err
+	}
+	repo := findRelease(repos, artifact.Version)
+	if repo == nil {
+		return fmt.Errorf("cannot find version: %s", artifact.Version)
+	}
+	shasumFilename := fmt.Sprintf("%s_%s_SHA256SUMS", provider, artifact.Version)
+	var asset, shasums *github.ReleaseAsset
+	for _, a := range repo.Assets {
+		switch *a.Name {
+		case artifact.Filename:
+			asset = a
+		case shasumFilename:
+			shasums = a
+		}
+	}
+	if asset == nil {
+		return fmt.Errorf("cannot find asset: %s", artifact.Filename)
+	}
+
+	c.Set("namespace", artifact.Namespace)
+	c.Set("provider", provider)
+	url, err := client.GetURL(c, asset)
+	if err != nil {
+		return err
+	}
+	shasum := ""
+	if strings.HasSuffix(artifact.Filename, ".zip") {
+		if shasums == nil {
+			return fmt.Errorf("cannot find asset: %s", shasumFilename)
+		}
+		shasumURL, err := client.GetURL(c, shasums)
+		if err != nil {
+			return err
+		}
+		if shasum, err = download.GetShasum(c.Request().Context(), opts.HTTPClient, artifact.Filename, shasumURL); err != nil {
+			return err
+		}
+	}
+	return opts.Mirror.Fetch(c.Request().Context(), artifact, url, shasum)
+}
+
+func contentType(filename string) string {
+	switch {
+	case strings.HasSuffix(filename, ".zip"):
+		return "application/zip"
+	case strings.HasSuffix(filename, ".sig"):
+		return echo.MIMEOctetStream
+	default:
+		return echo.MIMETextPlainCharsetUTF8
+	}
+}
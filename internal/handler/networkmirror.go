@@ -0,0 +1,150 @@
+/*
+Copyright © 2020 Andy Lo-A-Foe
+
+Permission is hereby granted, free of charge, to any person obtaining a copy
+of this software and associated documentation files (the "Software"), to deal
+in the Software without restriction, including without limitation the rights
+to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+copies of the Software, and to permit persons to whom the Software is
+furnished to do so, subject to the following conditions:
+
+The above copyright notice and this permission notice shall be included in
+all copies or substantial portions of the Software.
+
+THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+THE SOFTWARE.
+*/
+package handler
+
+import (
+	"fmt"
+	"net/http"
+	"strings"
+
+	"terraform-registry/internal/auth"
+	"terraform-registry/internal/client"
+	"terraform-registry/internal/mirror"
+	"terraform-registry/internal/models"
+	"terraform-registry/internal/parser"
+	"terraform-registry/internal/policy"
+
+	"github.com/labstack/echo/v4"
+)
+
+// NetworkMirrorPath is the path the provider network mirror protocol is served below
+const NetworkMirrorPath = "/v1/network-mirror/"
+
+// NetworkMirrorHandler implements the provider network mirror protocol for the providers
+// on GitHub, answering index.json and <version>.json. The hostname is not checked, so
+// terraform can mirror the providers of this registry under any name.
+func NetworkMirrorHandler(client *client.Client, opts Options) echo.HandlerFunc {
+	return func(c echo.Context) error {
+		namespace := c.Param("namespace")
+		typeParam := c.Param("type")
+		file := c.Param("file")
+		provider := "terraform-provider-" + typeParam
+
+		request := policy.Request{
+			Identity:  auth.GetIdentity(c),
+			Namespace: namespace,
+			Type:      typeParam,
+		}
+		if decision := opts.Policy.Evaluate(request); !decision.Allowed {
+			return forbidden(c, decision)
+		}
+		version, ok := strings.CutSuffix(file, ".json")
+		if !ok {
+			return c.JSON(http.StatusNotFound, &models.ErrorResponse{
+				Status:  http.StatusNotFound,
+				Message: fmt.Sprintf("cannot find %s", file),
+			})
+		}
+		if version != "index" {
+			request.Version = version
+			if decision := opts.Policy.Evaluate(request); !decision.Allowed {
+				return forbidden(c, decision)
+			}
+		}
+
+		repos, resp, err := client.ListReleases(c.Request().Context(), namespace, provider)
+		if err != nil {
+			status := upstreamStatus(err, http.StatusBadGateway)
+			if resp != nil && resp.StatusCode == http.StatusNotFound {
+				status = http.StatusNotFound
+			}
+			return c.JSON(status, &models.ErrorResponse{
+				Status:  status,
+				Message: err.Error(),
+			})
+		}
+
+		if version == "index" {
+			versions, err := parser.ParseVersions(repos)
+			if err != nil {
+				return c.JSON(http.StatusBadGateway, &models.ErrorResponse{
+					Status:  http.StatusBadGateway,
+					Message: err.Error(),
+				})
+			}
+			request.Version = ""
+			allowed, _ := filterVersions(opts.Policy, request, versions)
+			index := &models.NetworkMirrorIndex{Versions: make(map[string]struct{})}
+			for _, v := range availablePlatforms(opts.Verifier, namespace, typeParam, allowed) {
+				if len(v.Platforms) > 0 {
+					index.Versions[v.Version] = struct{}{}
+				}
+			}
+			return c.JSON(http.StatusOK, index)
+		}
+
+		release := findRelease(repos, version)
+		if release == nil {
+			return c.JSON(http.StatusNotFound, &models.ErrorResponse{
+				Status:  http.StatusNotFound,
+				Message: fmt.Sprintf("cannot find version: %s", version),
+			})
+		}
+		zips, err := releaseHashes(c, client, opts, namespace, typeParam, release, version, nil)
+		if err != nil {
+			status := upstreamStatus(err, http.StatusBadGateway)
+			return c.JSON(status, &models.ErrorResponse{
+				Status:  status,
+				Message: fmt.Sprintf("failed hashing %s/%s %s: %v", namespace, typeParam, version, err),
+			})
+		}
+		response := &models.NetworkMirrorVersion{Archives: make(map[string]models.NetworkMirrorArchive, len(zips))}
+		for platform, zip := range zips {
+			url, err := archiveURL(c, client, opts.Mirror, version, zip)
+			if err != nil {
+				status := upstreamStatus(err, http.StatusBadGateway)
+				return c.JSON(status, &models.ErrorResponse{
+					Status:  status,
+					Message: err.Error(),
+				})
+			}
+			response.Archives[platform] = models.NetworkMirrorArchive{
+				URL:    url,
+				Hashes: []string{zip.hashes.H1},
+			}
+		}
+		return c.JSON(http.StatusOK, response)
+	}
+}
+
+// archiveURL returns the signed mirror URL of a platform zip when mirroring, its GitHub URL otherwise
+func archiveURL(c echo.Context, client *client.Client, m *mirror.Mirror, version string, zip platformZip) (string, error) {
+	if m != nil {
+		return m.URL(mirror.Artifact{
+			Namespace: c.Param("namespace"),
+			Type:      c.Param("type"),
+			Version:   version,
+			Filename:  *zip.asset.Name,
+		}), nil
+	}
+	return client.GetURL(c, zip.asset)
+}
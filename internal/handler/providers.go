@@ -0,0 +1,144 @@
+/*
+Copyright © 2020 Andy Lo-A-Foe
+
+Permission is hereby granted, free of charge, to any person obtaining a copy
+of this software and associated documentation files (the "Software"), to deal
+in the Software without restriction, including without limitation the rights
+to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+copies of the Software, and to permit persons to whom the Software is
+furnished to do so, subject to the following conditions:
+
+The above copyright notice and this permission notice shall be included in
+all copies or substantial portions of the Software.
+
+THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+THE SOFTWARE.
+*/
+package handler
+
+import (
+	"fmt"
+	"net/http"
+	"sort"
+	"strings"
+
+	"terraform-registry/internal/auth"
+	"terraform-registry/internal/client"
+	"terraform-registry/internal/logging"
+	"terraform-registry/internal/models"
+	"terraform-registry/internal/parser"
+	"terraform-registry/internal/policy"
+
+	"github.com/google/go-github/v32/github"
+	"github.com/labstack/echo/v4"
+)
+
+// ProvidersHandler returns the handler listing the providers of a namespace, or
+// searching the providers of all owners with the q query parameter when no namespace
+// is given. Owners limit the GitHub owners whose providers are listed.
+func ProvidersHandler(client *client.Client, opts Options) echo.HandlerFunc {
+	return func(c echo.Context) error {
+		namespace := c.Param("namespace")
+		var repos []*github.Repository
+		var err error
+		switch {
+		case namespace != "":
+			owner := client.Owner(namespace)
+			if len(opts.Owners) > 0 && !containsFold(opts.Owners, owner) {
+				return c.JSON(http.StatusNotFound, &models.ErrorResponse{
+					Status:  http.StatusNotFound,
+					Message: fmt.Sprintf("namespace %s is not hosted here", namespace),
+				})
+			}
+			repos, err = client.ListProviderRepos(c.Request().Context(), owner)
+		case len(opts.Owners) == 0:
+			return c.JSON(http.StatusNotFound, &models.ErrorResponse{
+				Status:  http.StatusNotFound,
+				Message: "no owners are configured for searching",
+			})
+		case c.QueryParam("q") != "":
+			repos, err = client.SearchProviderRepos(c.Request().Context(), c.QueryParam("q"), opts.Owners)
+		default:
+			for _, owner := range opts.Owners {
+				var owned []*github.Repository
+				if owned, err = client.ListProviderRepos(c.Request().Context(), owner); err != nil {
+					break
+				}
+				repos = append(repos, owned...)
+			}
+		}
+		if err != nil {
+			status := upstreamStatus(err, http.StatusBadGateway)
+			return c.JSON(status, &models.ErrorResponse{
+				Status:  status,
+				Message: err.Error(),
+			})
+		}
+
+		response := &models.ProviderListResponse{
+			Providers: make([]models.ProviderSummary, 0, len(repos)),
+		}
+		for _, repo := range repos {
+			// Searches may still turn up repositories of other owners
+			if len(opts.Owners) > 0 && !containsFold(opts.Owners, repo.GetOwner().GetLogin()) {
+				continue
+			}
+			if summary, ok := providerSummary(c, client, opts, repo); ok {
+				response.Providers = append(response.Providers, summary)
+			}
+		}
+		sort.Slice(response.Providers, func(i, j int) bool {
+			return response.Providers[i].ID < response.Providers[j].ID
+		})
+		return c.JSON(http.StatusOK, response)
+	}
+}
+
+// providerSummary describes a provider repository with its latest version the caller
+// may access, reporting false when the policy hides the provider
+func providerSummary(c echo.Context, client *client.Client, opts Options, repo *github.Repository) (models.ProviderSummary, bool) {
+	namespace := client.Namespace(repo.GetOwner().GetLogin())
+	typeParam := strings.TrimPrefix(repo.GetName(), "terraform-provider-")
+	request := policy.Request{
+		Identity:  auth.GetIdentity(c),
+		Namespace: namespace,
+		Type:      typeParam,
+	}
+	if decision := opts.Policy.Evaluate(request); !decision.Allowed {
+		return models.ProviderSummary{}, false
+	}
+	summary := models.ProviderSummary{
+		ID:          namespace + "/" + typeParam,
+		Namespace:   namespace,
+		Name:        typeParam,
+		Description: repo.GetDescription(),
+		Source:      repo.GetHTMLURL(),
+	}
+	releases, _, err := client.ListReleases(c.Request().Context(), namespace, repo.GetName())
+	if err != nil {
+		logging.FromContext(c.Request().Context()).Warn("listing releases", "provider", summary.ID, "error", err)
+		return summary, true
+	}
+	versions, _ := parser.ParseVersions(releases)
+	allowed, _ := filterVersions(opts.Policy, request, versions)
+	matches, _ := parser.MatchVersions(availablePlatforms(opts.Verifier, namespace, typeParam, allowed), "")
+	if len(matches) > 0 {
+		summary.LatestVersion = matches[0].Version
+		summary.Platforms = len(matches[0].Platforms)
+	}
+	return summary, true
+}
+
+func containsFold(values []string, value string) bool {
+	for _, v := range values {
+		if strings.EqualFold(v, value) {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,88 @@
+/*
+Copyright © 2020 Andy Lo-A-Foe
+
+Permission is hereby granted, free of charge, to any person obtaining a copy
+of this software and associated documentation files (the "Software"), to deal
+in the Software without restriction, including without limitation the rights
+to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+copies of the Software, and to permit persons to whom the Software is
+furnished to do so, subject to the following conditions:
+
+The above copyright notice and this permission notice shall be included in
+all copies or substantial portions of the Software.
+
+THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+THE SOFTWARE.
+*/
+package handler
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strings"
+
+	"terraform-registry/internal/models"
+	"terraform-registry/internal/policy"
+	"terraform-registry/internal/upstream"
+
+	"github.com/labstack/echo/v4"
+)
+
+// proxyUpstream forwards a provider request to the upstream registry, applying the
+// version policies to the versions it lists. Constraints and latest are resolved locally.
+func proxyUpstream(c echo.Context, registry *upstream.Registry, engine *policy.Engine, request policy.Request, param string) error {
+	if param == "resolve" || isLatest(param) {
+		resp, err := registry.Get(c.Request().Context(), request.Namespace+"/"+request.Type+"/versions")
+		if err != nil {
+			return upstreamError(c, registry, err)
+		}
+		if resp.Status != http.StatusOK {
+			return c.JSONBlob(resp.Status, resp.Body)
+		}
+		var response models.VersionResponse
+		if err := json.Unmarshal(resp.Body, &response); err != nil {
+			return upstreamError(c, registry, err)
+		}
+		if param == "resolve" {
+			return resolveVersions(c, Options{Policy: engine}, request, response.Versions)
+		}
+		version, ok := latestVersion(Options{Policy: engine}, request, response.Versions)
+		if !ok {
+			return noLatestVersion(c)
+		}
+		param = version + strings.TrimPrefix(param, "latest")
+	}
+
+	resp, err := registry.Get(c.Request().Context(), request.Namespace+"/"+request.Type+"/"+param)
+	if err != nil {
+		return upstreamError(c, registry, err)
+	}
+	if param != "versions" || resp.Status != http.StatusOK {
+		return c.JSONBlob(resp.Status, resp.Body)
+	}
+
+	var response models.VersionResponse
+	if err := json.Unmarshal(resp.Body, &response); err != nil {
+		return upstreamError(c, registry, err)
+	}
+	allowed, denied := filterVersions(engine, request, response.Versions)
+	if len(allowed) == 0 && len(response.Versions) > 0 {
+		return forbidden(c, denied)
+	}
+	response.Versions = allowed
+	return c.JSON(http.StatusOK, &response)
+}
+
+func upstreamError(c echo.Context, registry *upstream.Registry, err error) error {
+	status := upstreamStatus(err, http.StatusBadGateway)
+	return c.JSON(status, &models.ErrorResponse{
+		Status:  status,
+		Message: fmt.Sprintf("upstream registry %s: %v", registry.Host(), err),
+	})
+}
@@ -0,0 +1,160 @@
+/*
+Copyright © 2020 Andy Lo-A-Foe
+
+Permission is hereby granted, free of charge, to any person obtaining a copy
+of this software and associated documentation files (the "Software"), to deal
+in the Software without restriction, including without limitation the rights
+to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+copies of the Software, and to permit persons to whom the Software is
+furnished to do so, subject to the following conditions:
+
+The above copyright notice and this permission notice shall be included in
+all copies or substantial portions of the Software.
+
+THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+THE SOFTWARE.
+*/
+package hashes
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"io"
+	"os"
+	"strings"
+	"sync"
+
+	"terraform-registry/internal/download"
+
+	"golang.org/x/mod/sumdb/dirhash"
+)
+
+// Hashes are the hashes terraform records for a provider zip in .terraform.lock.hcl
+type Hashes struct {
+	// H1 is the hash of the files inside the zip
+	H1 string
+	// ZH is the SHA256 of the zip itself, as listed in SHA256SUMS
+	ZH string
+}
+
+// ZH formats a SHA256SUMS entry as zh: hash
+func ZH(shasum string) string {
+	return "zh:" + strings.ToLower(shasum)
+}
+
+// Compute reads a provider zip and computes its hashes
+func Compute(r io.Reader) (*Hashes, error) {
+	tmp, err := os.CreateTemp("", "provider-*.zip")
+	if err != nil {
+		return nil, err
+	}
+	defer func() { _ = os.Remove(tmp.Name()) }()
+
+	sum := sha256.New()
+	if _, err := io.Copy(io.MultiWriter(tmp, sum), r); err != nil {
+		_ = tmp.Close()
+		return nil, err
+	}
+	if err := tmp.Close(); err != nil {
+		return nil, err
+	}
+	h1, err := dirhash.HashZip(tmp.Name(), dirhash.Hash1)
+	if err != nil {
+		return nil, err
+	}
+	return &Hashes{
+		H1: h1,
+		ZH: ZH(hex.EncodeToString(sum.Sum(nil))),
+	}, nil
+}
+
+// Hasher computes the hashes of provider zips, remembering them since released
+// zips do not change. A nil Hasher computes the hashes on every request.
+type Hasher struct {
+	mu       sync.Mutex
+	results  map[string]*Hashes
+	inflight map[string]*computation
+}
+
+// computation is a running computation of the hashes of a zip, shared by the requests
+// asking for it while it runs
+type computation struct {
+	done   chan struct{}
+	hashes *Hashes
+	err    error
+}
+
+// New creates a new Hasher
+func New() *Hasher {
+	return &Hasher{
+		results:  make(map[string]*Hashes),
+		inflight: make(map[string]*computation),
+	}
+}
+
+// Lookup returns the hashes known for key
+func (h *Hasher) Lookup(key string) (*Hashes, bool) {
+	if h == nil {
+		return nil, false
+	}
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	hashes, ok := h.results[key]
+	return hashes, ok
+}
+
+// Get returns the hashes for key, computing them from the zip returned by open
+// when they are not known yet. The zip must match shasum, its SHA256SUMS entry.
+// Concurrent requests for the same key share a single computation.
+func (h *Hasher) Get(key, shasum string, open func() (io.ReadCloser, error)) (*Hashes, error) {
+	if h == nil {
+		return compute(shasum, open)
+	}
+	h.mu.Lock()
+	if hashes, ok := h.results[key]; ok {
+		h.mu.Unlock()
+		return hashes, nil
+	}
+	if running, ok := h.inflight[key]; ok {
+		h.mu.Unlock()
+		<-running.done
+		return running.hashes, running.err
+	}
+	running := &computation{done: make(chan struct{})}
+	h.inflight[key] = running
+	h.mu.Unlock()
+
+	running.hashes, running.err = compute(shasum, open)
+
+	h.mu.Lock()
+	if running.err == nil {
+		h.results[key] = running.hashes
+	}
+	delete(h.inflight, key)
+	h.mu.Unlock()
+	close(running.done)
+	return running.hashes, running.err
+}
+
+// compute hashes the zip returned by open, checking it against shasum
+func compute(shasum string, open func() (io.ReadCloser, error)) (*Hashes, error) {
+	r, err := open()
+	if err != nil {
+		return nil, err
+	}
+	defer func() { _ = r.Close() }()
+
+	hashes, err := Compute(r)
+	if err != nil {
+		return nil, err
+	}
+	if hashes.ZH != ZH(shasum) {
+		return nil, download.ErrChecksumMismatch
+	}
+	return hashes, nil
+}
@@ -0,0 +1,168 @@
+/*
+Copyright © 2020 Andy Lo-A-Foe
+
+Permission is hereby granted, free of charge, to any person obtaining a copy
+of this software and associated documentation files (the "Software"), to deal
+in the Software without restriction, including without limitation the rights
+to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+copies of the Software, and to permit persons to whom the Software is
+furnished to do so, subject to the following conditions:
+
+The above copyright notice and this permission notice shall be included in
+all copies or substantial portions of the Software.
+
+THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+THE SOFTWARE.
+*/
+package hashes
+
+import (
+	"archive/zip"
+	"bytes"
+	"crypto/sha256"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"terraform-registry/internal/download"
+
+	"golang.org/x/mod/sumdb/dirhash"
+)
+
+func testZip(t *testing.T, files map[string]string) []byte {
+	t.Helper()
+	var buf bytes.Buffer
+	zw := zip.NewWriter(&buf)
+	for name, content := range files {
+		f, err := zw.Create(name)
+		if err != nil {
+			t.Fatalf("zip Create() error = %v", err)
+		}
+		_, _ = f.Write([]byte(content))
+	}
+	_ = zw.Close()
+	return buf.Bytes()
+}
+
+func TestCompute(t *testing.T) {
+	data := testZip(t, map[string]string{
+		"terraform-provider-hsdp_v1.0.0": "binary",
+		"LICENSE":                        "MIT",
+	})
+	dir := t.TempDir()
+	path := filepath.Join(dir, "provider.zip")
+	if err := os.WriteFile(path, data, 0600); err != nil {
+		t.Fatalf("WriteFile() error = %v", err)
+	}
+	wantH1, err := dirhash.HashZip(path, dirhash.Hash1)
+	if err != nil {
+		t.Fatalf("HashZip() error = %v", err)
+	}
+
+	got, err := Compute(bytes.NewReader(data))
+	if err != nil {
+		t.Fatalf("Compute() error = %v", err)
+	}
+	if got.H1 != wantH1 {
+		t.Errorf("Compute() H1 = %v, want %v", got.H1, wantH1)
+	}
+	if wantZH := fmt.Sprintf("zh:%x", sha256.Sum256(data)); got.ZH != wantZH {
+		t.Errorf("Compute() ZH = %v, want %v", got.ZH, wantZH)
+	}
+
+	if _, err := Compute(bytes.NewReader([]byte("not a zip"))); err == nil {
+		t.Error("Compute() expected error for invalid zip")
+	}
+}
+
+func TestHasherGet(t *testing.T) {
+	data := testZip(t, map[string]string{"terraform-provider-hsdp_v1.0.0": "binary"})
+	shasum := fmt.Sprintf("%X", sha256.Sum256(data))
+	opens := 0
+	open := func() (io.ReadCloser, error) {
+		opens++
+		return io.NopCloser(bytes.NewReader(data)), nil
+	}
+
+	tests := []struct {
+		name    string
+		key     string
+		shasum  string
+		wantErr error
+	}{
+		{"match", "hsdp_linux_amd64", shasum, nil},
+		{"cached", "hsdp_linux_amd64", "ignored", nil},
+		{"mismatch", "hsdp_darwin_arm64", "0000", download.ErrChecksumMismatch},
+	}
+	h := New()
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := h.Get(tt.key, tt.shasum, open)
+			if !errors.Is(err, tt.wantErr) {
+				t.Errorf("Get() error = %v, wantErr %v", err, tt.wantErr)
+			}
+		})
+	}
+	if opens != 2 {
+		t.Errorf("Get() opened the zip %d times, want 2", opens)
+	}
+	if _, ok := h.Lookup("hsdp_darwin_arm64"); ok {
+		t.Error("Lookup() found hashes of a mismatching zip")
+	}
+}
+
+func TestHasherGetConcurrent(t *testing.T) {
+	data := testZip(t, map[string]string{"terraform-provider-hsdp_v1.0.0": "binary"})
+	shasum := fmt.Sprintf("%X", sha256.Sum256(data))
+	var opens atomic.Int32
+	release := make(chan struct{})
+	open := func() (io.ReadCloser, error) {
+		opens.Add(1)
+		<-release
+		return io.NopCloser(bytes.NewReader(data)), nil
+	}
+
+	h := New()
+	var wg sync.WaitGroup
+	for i := 0; i < 5; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			if _, err := h.Get("hsdp_linux_amd64", shasum, open); err != nil {
+				t.Errorf("Get() error = %v", err)
+			}
+		}()
+	}
+	time.Sleep(20 * time.Millisecond)
+	close(release)
+	wg.Wait()
+	if n := opens.Load(); n != 1 {
+		t.Errorf("Get() opened the zip %d times, want 1", n)
+	}
+}
+
+func TestNilHasher(t *testing.T) {
+	data := testZip(t, map[string]string{"terraform-provider-hsdp_v1.0.0": "binary"})
+	shasum := fmt.Sprintf("%X", sha256.Sum256(data))
+	var h *Hasher
+	if _, ok := h.Lookup("hsdp_linux_amd64"); ok {
+		t.Error("Lookup() of nil Hasher found hashes")
+	}
+	got, err := h.Get("hsdp_linux_amd64", shasum, func() (io.ReadCloser, error) {
+		return io.NopCloser(bytes.NewReader(data)), nil
+	})
+	if err != nil || got.ZH != ZH(shasum) {
+		t.Errorf("Get() of nil Hasher = %v, %v", got, err)
+	}
+}
@@ -0,0 +1,128 @@
+/*
+Copyright © 2020 Andy Lo-A-Foe
+
+Permission is hereby granted, free of charge, to any person obtaining a copy
+of this software and associated documentation files (the "Software"), to deal
+in the Software without restriction, including without limitation the rights
+to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+copies of the Software, and to permit persons to whom the Software is
+furnished to do so, subject to the following conditions:
+
+The above copyright notice and this permission notice shall be included in
+all copies or substantial portions of the Software.
+
+THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+THE SOFTWARE.
+*/
+package health
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"time"
+
+	"terraform-registry/internal/client"
+	"terraform-registry/internal/models"
+
+	"github.com/google/go-github/v32/github"
+	"github.com/labstack/echo/v4"
+)
+
+const (
+	// LivenessPath is where the liveness probe is served
+	LivenessPath = "/healthz"
+	// ReadinessPath is where the readiness probe is served
+	ReadinessPath = "/readyz"
+
+	// StatusOK marks a passing check
+	StatusOK = "ok"
+	// StatusFailed marks a failing check
+	StatusFailed = "failed"
+)
+
+// checkTimeout bounds the GitHub call of a readiness check
+const checkTimeout = 5 * time.Second
+
+// Checker answers the liveness and readiness probes of the registry
+type Checker struct {
+	client *client.Client
+}
+
+// New creates a Checker probing GitHub through client
+func New(client *client.Client) *Checker {
+	return &Checker{client: client}
+}
+
+// LivenessHandler reports the process is alive
+func (h *Checker) LivenessHandler() echo.HandlerFunc {
+	return func(c echo.Context) error {
+		return c.JSON(http.StatusOK, &models.HealthResponse{Status: StatusOK})
+	}
+}
+
+// ReadinessHandler reports whether GitHub is reachable with valid credentials. Readiness fails
+// when the rate limit is exhausted and no releases are cached. The registry does not start
+// without a valid configuration, so there is no need to check it here.
+func (h *Checker) ReadinessHandler() echo.HandlerFunc {
+	return func(c echo.Context) error {
+		ctx, cancel := context.WithTimeout(c.Request().Context(), checkTimeout)
+		defer cancel()
+
+		response := &models.HealthResponse{Status: StatusOK, Checks: make(map[string]models.HealthCheck)}
+		rate, err := h.client.RateLimit(ctx)
+		var limited *github.RateLimitError
+		if errors.As(err, &limited) {
+			// go-github answers from the last seen rate limit once it is exhausted
+			rate, err = &limited.Rate, nil
+		}
+		response.Checks["github"] = h.github(err)
+		response.Checks["cache"] = h.cache(rate)
+
+		status := http.StatusOK
+		for _, check := range response.Checks {
+			if check.Status != StatusOK {
+				response.Status = StatusFailed
+				status = http.StatusServiceUnavailable
+			}
+		}
+		return c.JSON(status, response)
+	}
+}
+
+func (h *Checker) github(err error) models.HealthCheck {
+	if err != nil {
+		var resp *github.ErrorResponse
+		if errors.As(err, &resp) && resp.Response != nil && resp.Response.StatusCode == http.StatusUnauthorized {
+			return failed("invalid GitHub credentials")
+		}
+		return failed(fmt.Sprintf("GitHub unreachable: %v", err))
+	}
+	if h.client.Authenticated {
+		return models.HealthCheck{Status: StatusOK, Message: "authenticated"}
+	}
+	return models.HealthCheck{Status: StatusOK, Message: "anonymous"}
+}
+
+// cache checks requests can still be answered, either within the rate limit or from the release cache
+func (h *Checker) cache(rate *github.Rate) models.HealthCheck {
+	cached := h.client.CachedReleases()
+	if rate == nil || rate.Remaining > 0 {
+		return models.HealthCheck{Status: StatusOK, Message: fmt.Sprintf("%d repositories cached", cached)}
+	}
+	if cached == 0 {
+		return failed(fmt.Sprintf("rate limit exhausted until %s and no releases cached",
+			rate.Reset.UTC().Format(time.RFC3339)))
+	}
+	return models.HealthCheck{Status: StatusOK, Message: fmt.Sprintf("rate limit exhausted, serving %d cached repositories", cached)}
+}
+
+func failed(message string) models.HealthCheck {
+	return models.HealthCheck{Status: StatusFailed, Message: message}
+}
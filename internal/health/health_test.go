@@ -0,0 +1,145 @@
+/*
+Copyright © 2020 Andy Lo-A-Foe
+
+Permission is hereby granted, free of charge, to any person obtaining a copy
+of this software and associated documentation files (the "Software"), to deal
+in the Software without restriction, including without limitation the rights
+to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+copies of the Software, and to permit persons to whom the Software is
+furnished to do so, subject to the following conditions:
+
+The above copyright notice and this permission notice shall be included in
+all copies or substantial portions of the Software.
+
+THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+THE SOFTWARE.
+*/
+package health
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strconv"
+	"testing"
+	"time"
+
+	"terraform-registry/internal/client"
+	"terraform-registry/internal/models"
+
+	"github.com/google/go-github/v32/github"
+	"github.com/labstack/echo/v4"
+)
+
+func newChecker(t *testing.T, status, remaining int) *Checker {
+	t.Helper()
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if status != http.StatusOK {
+			w.WriteHeader(status)
+			_, _ = w.Write([]byte(`{"message":"Bad credentials"}`))
+			return
+		}
+		_ = json.NewEncoder(w).Encode(map[string]interface{}{
+			"resources": map[string]interface{}{
+				"core": map[string]interface{}{"limit": 5000, "remaining": remaining, "reset": time.Now().Add(time.Hour).Unix()},
+			},
+		})
+	}))
+	t.Cleanup(server.Close)
+	gh := github.NewClient(nil)
+	gh.BaseURL, _ = url.Parse(server.URL + "/")
+	return New(&client.Client{Github: gh, Authenticated: true})
+}
+
+func probe(handler echo.HandlerFunc) (int, models.HealthResponse) {
+	e := echo.New()
+	rec := httptest.NewRecorder()
+	c := e.NewContext(httptest.NewRequest(http.MethodGet, "/", nil), rec)
+	_ = handler(c)
+	var response models.HealthResponse
+	_ = json.Unmarshal(rec.Body.Bytes(), &response)
+	return rec.Code, response
+}
+
+func TestLivenessHandler(t *testing.T) {
+	code, response := probe(New(nil).LivenessHandler())
+	if code != http.StatusOK || response.Status != StatusOK {
+		t.Errorf("LivenessHandler() = %d %s, want %d %s", code, response.Status, http.StatusOK, StatusOK)
+	}
+}
+
+func TestReadinessHandler(t *testing.T) {
+	tests := []struct {
+		name       string
+		status     int
+		remaining  int
+		wantCode   int
+		wantFailed string
+	}{
+		{name: "Ready", status: http.StatusOK, remaining: 100, wantCode: http.StatusOK},
+		{name: "Invalid credentials", status: http.StatusUnauthorized, wantCode: http.StatusServiceUnavailable, wantFailed: "github"},
+		{name: "Rate limit exhausted", status: http.StatusOK, remaining: 0, wantCode: http.StatusServiceUnavailable, wantFailed: "cache"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			checker := newChecker(t, tt.status, tt.remaining)
+
+			code, response := probe(checker.ReadinessHandler())
+			if code != tt.wantCode {
+				t.Errorf("ReadinessHandler() status = %d, want %d: %+v", code, tt.wantCode, response)
+			}
+			for name, check := range response.Checks {
+				if failed := check.Status != StatusOK; failed != (name == tt.wantFailed) {
+					t.Errorf("ReadinessHandler() check %s = %+v", name, check)
+				}
+			}
+		})
+	}
+}
+
+func TestReadinessHandlerRateLimited(t *testing.T) {
+	// Once go-github has seen an exhausted rate limit it answers from it without a request
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("X-RateLimit-Limit", "60")
+		w.Header().Set("X-RateLimit-Remaining", "0")
+		w.Header().Set("X-RateLimit-Reset", strconv.FormatInt(time.Now().Add(time.Hour).Unix(), 10))
+		_, _ = w.Write([]byte(`[]`))
+	}))
+	defer server.Close()
+
+	tests := []struct {
+		name     string
+		ttl      time.Duration
+		wantCode int
+	}{
+		{name: "Warm cache", ttl: time.Hour, wantCode: http.StatusOK},
+		{name: "No cache", ttl: 0, wantCode: http.StatusServiceUnavailable},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			c, err := client.NewClient(client.WithEnterprise(server.URL+"/", server.URL+"/"), client.WithReleaseCacheTTL(tt.ttl))
+			if err != nil {
+				t.Fatalf("NewClient() error = %v", err)
+			}
+			if _, _, err := c.ListReleases(context.Background(), "philips", "terraform-provider-hsdp"); err != nil {
+				t.Fatalf("ListReleases() error = %v", err)
+			}
+
+			code, response := probe(New(c).ReadinessHandler())
+			if code != tt.wantCode {
+				t.Errorf("ReadinessHandler() status = %d, want %d: %+v", code, tt.wantCode, response)
+			}
+			if check := response.Checks["github"]; check.Status != StatusOK {
+				t.Errorf("ReadinessHandler() check github = %+v", check)
+			}
+		})
+	}
+}
@@ -0,0 +1,203 @@
+/*
+Copyright © 2020 Andy Lo-A-Foe
+
+Permission is hereby granted, free of charge, to any person obtaining a copy
+of this software and associated documentation files (the "Software"), to deal
+in the Software without restriction, including without limitation the rights
+to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+copies of the Software, and to permit persons to whom the Software is
+furnished to do so, subject to the following conditions:
+
+The above copyright notice and this permission notice shall be included in
+all copies or substantial portions of the Software.
+
+THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+THE SOFTWARE.
+*/
+package logging
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"log/slog"
+	"net/http"
+	"sync"
+	"time"
+
+	"github.com/labstack/echo/v4"
+)
+
+// Log formats
+const (
+	JSON = "json"
+	Text = "text"
+)
+
+// Options configure the log output
+type Options struct {
+	// Format is JSON or Text
+	Format string `yaml:"format"`
+	// Level is the lowest level logged: debug, info, warn or error
+	Level string `yaml:"level"`
+}
+
+// Validate checks the format and level
+func (o Options) Validate() error {
+	var errs []error
+	if o.Format != JSON && o.Format != Text {
+		errs = append(errs, fmt.Errorf("log.format must be %q or %q, not %q", JSON, Text, o.Format))
+	}
+	var level slog.Level
+	if err := level.UnmarshalText([]byte(o.Level)); err != nil {
+		errs = append(errs, fmt.Errorf("log.level must be debug, info, warn or error, not %q", o.Level))
+	}
+	return errors.Join(errs...)
+}
+
+// New creates a logger writing to w
+func New(w io.Writer, o Options) (*slog.Logger, error) {
+	if err := o.Validate(); err != nil {
+		return nil, err
+	}
+	var level slog.Level
+	_ = level.UnmarshalText([]byte(o.Level))
+	handlerOptions := &slog.HandlerOptions{Level: level}
+	if o.Format == Text {
+		return slog.New(slog.NewTextHandler(w, handlerOptions)), nil
+	}
+	return slog.New(slog.NewJSONHandler(w, handlerOptions)), nil
+}
+
+type loggerKey struct{}
+
+type entryKey struct{}
+
+// entry collects what is logged about a request while it is handled
+type entry struct {
+	mu       sync.Mutex
+	attrs    []any
+	upstream time.Duration
+	calls    int
+}
+
+// FromContext returns the logger of the request ctx belongs to, which logs its request ID,
+// or the default logger outside of requests
+func FromContext(ctx context.Context) *slog.Logger {
+	if logger, ok := ctx.Value(loggerKey{}).(*slog.Logger); ok {
+		return logger
+	}
+	return slog.Default()
+}
+
+// Add adds attributes, as key value pairs, to the log entry of the request ctx belongs to
+func Add(ctx context.Context, args ...any) {
+	if e, ok := ctx.Value(entryKey{}).(*entry); ok {
+		e.mu.Lock()
+		e.attrs = append(e.attrs, args...)
+		e.mu.Unlock()
+	}
+}
+
+// routeParams are the route parameters logged for every request
+var routeParams = []string{"namespace", "type", "version"}
+
+// Middleware logs a single entry for every request, with its request ID, path, route parameters,
+// status, latency and the time spent waiting on upstream servers. Failed requests are
+// logged with their error class. The request ID is taken from the X-Request-ID response
+// header, so middleware.RequestID must run first. Query strings are left out since they can
+// carry OAuth codes and mirror URL signatures.
+func Middleware(logger *slog.Logger) echo.MiddlewareFunc {
+	return func(next echo.HandlerFunc) echo.HandlerFunc {
+		return func(c echo.Context) error {
+			start := time.Now()
+			req := c.Request()
+			id := c.Response().Header().Get(echo.HeaderXRequestID)
+			e := &entry{}
+			ctx := context.WithValue(req.Context(), entryKey{}, e)
+			ctx = context.WithValue(ctx, loggerKey{}, logger.With("request_id", id))
+			c.SetRequest(req.WithContext(ctx))
+
+			err := next(c)
+			status := c.Response().Status
+			if err != nil {
+				status = http.StatusInternalServerError
+				if httpErr, ok := err.(*echo.HTTPError); ok {
+					status = httpErr.Code
+				}
+			}
+
+			args := []any{
+				"request_id", id,
+				"method", req.Method,
+				"path", req.URL.Path,
+				"route", c.Path(),
+				"remote_ip", c.RealIP(),
+				"status", status,
+				"latency_ms", milliseconds(time.Since(start)),
+				"bytes_out", c.Response().Size,
+			}
+			for _, name := range routeParams {
+				if value := c.Param(name); value != "" {
+					args = append(args, name, value)
+				}
+			}
+			e.mu.Lock()
+			args = append(args, e.attrs...)
+			if e.calls > 0 {
+				args = append(args, "upstream_calls", e.calls, "upstream_latency_ms", milliseconds(e.upstream))
+			}
+			e.mu.Unlock()
+
+			level := slog.LevelInfo
+			if class := ErrorClass(status); class != "" {
+				args = append(args, "error_class", class)
+				if status >= http.StatusInternalServerError {
+					level = slog.LevelError
+				}
+			}
+			if err != nil {
+				args = append(args, "error", err.Error())
+			}
+			logger.Log(ctx, level, "request", args...)
+			return err
+		}
+	}
+}
+
+// ErrorClass groups failed requests by their cause, empty for successful requests
+func ErrorClass(status int) string {
+	switch {
+	case status == http.StatusGatewayTimeout:
+		return "timeout"
+	case status == http.StatusBadGateway:
+		return "upstream"
+	case status >= http.StatusInternalServerError:
+		return "internal"
+	case status == http.StatusUnauthorized || status == http.StatusForbidden:
+		return "auth"
+	case status >= http.StatusBadRequest:
+		return "client"
+	}
+	return ""
+}
+
+// AddUpstream adds an upstream call which took d to the log entry of the request ctx belongs to
+func AddUpstream(ctx context.Context, d time.Duration) {
+	if e, ok := ctx.Value(entryKey{}).(*entry); ok {
+		e.mu.Lock()
+		e.upstream += d
+		e.calls++
+		e.mu.Unlock()
+	}
+}
+
+func milliseconds(d time.Duration) float64 {
+	return float64(d.Microseconds()) / 1000
+}
@@ -0,0 +1,135 @@
+/*
+Copyright © 2020 Andy Lo-A-Foe
+
+Permission is hereby granted, free of charge, to any person obtaining a copy
+of this software and associated documentation files (the "Software"), to deal
+in the Software without restriction, including without limitation the rights
+to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+copies of the Software, and to permit persons to whom the Software is
+furnished to do so, subject to the following conditions:
+
+The above copyright notice and this permission notice shall be included in
+all copies or substantial portions of the Software.
+
+THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+THE SOFTWARE.
+*/
+package logging
+
+import (
+	"bytes"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/labstack/echo/v4"
+	"github.com/labstack/echo/v4/middleware"
+)
+
+func TestNew(t *testing.T) {
+	tests := []struct {
+		name    string
+		options Options
+		wantErr bool
+	}{
+		{"JSON", Options{Format: JSON, Level: "info"}, false},
+		{"Text", Options{Format: Text, Level: "debug"}, false},
+		{"Unknown format", Options{Format: "xml", Level: "info"}, true},
+		{"Unknown level", Options{Format: JSON, Level: "loud"}, true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := New(&bytes.Buffer{}, tt.options)
+			if (err != nil) != tt.wantErr {
+				t.Errorf("New() error = %v, wantErr %v", err, tt.wantErr)
+			}
+		})
+	}
+}
+
+func TestErrorClass(t *testing.T) {
+	tests := []struct {
+		status int
+		want   string
+	}{
+		{http.StatusOK, ""},
+		{http.StatusNotFound, "client"},
+		{http.StatusForbidden, "auth"},
+		{http.StatusBadGateway, "upstream"},
+		{http.StatusGatewayTimeout, "timeout"},
+		{http.StatusInternalServerError, "internal"},
+	}
+	for _, tt := range tests {
+		if got := ErrorClass(tt.status); got != tt.want {
+			t.Errorf("ErrorClass(%d) = %q, want %q", tt.status, got, tt.want)
+		}
+	}
+}
+
+func TestMiddleware(t *testing.T) {
+	var out bytes.Buffer
+	logger, err := New(&out, Options{Format: JSON, Level: "info"})
+	if err != nil {
+		t.Fatalf("New() error = %v", err)
+	}
+	e := echo.New()
+	e.Use(middleware.RequestID())
+	e.Use(Middleware(logger))
+	e.GET("/v1/providers/:namespace/:type/*", func(c echo.Context) error {
+		ctx := c.Request().Context()
+		Add(ctx, "version", "1.0.0", "platform", "linux_amd64")
+		AddUpstream(ctx, 5*time.Millisecond)
+		FromContext(ctx).Info("handling")
+		return c.NoContent(http.StatusBadGateway)
+	})
+
+	req := httptest.NewRequest(http.MethodGet, "/v1/providers/philips/hsdp/1.0.0/download/linux/amd64?signature=secret", nil)
+	req.Header.Set(echo.HeaderXRequestID, "abc")
+	e.ServeHTTP(httptest.NewRecorder(), req)
+
+	lines := bytes.Split(bytes.TrimSpace(out.Bytes()), []byte("\n"))
+	if len(lines) != 2 {
+		t.Fatalf("Middleware() logged %d lines, want 2: %s", len(lines), out.String())
+	}
+	var handling, entry map[string]any
+	if err := json.Unmarshal(lines[0], &handling); err != nil {
+		t.Fatalf("Unmarshal() error = %v", err)
+	}
+	if err := json.Unmarshal(lines[1], &entry); err != nil {
+		t.Fatalf("Unmarshal() error = %v", err)
+	}
+	if handling["request_id"] != "abc" {
+		t.Errorf("FromContext() request_id = %v, want abc", handling["request_id"])
+	}
+	want := map[string]any{
+		"level":          "ERROR",
+		"msg":            "request",
+		"path":           "/v1/providers/philips/hsdp/1.0.0/download/linux/amd64",
+		"request_id":     "abc",
+		"namespace":      "philips",
+		"type":           "hsdp",
+		"version":        "1.0.0",
+		"platform":       "linux_amd64",
+		"status":         float64(http.StatusBadGateway),
+		"error_class":    "upstream",
+		"upstream_calls": float64(1),
+	}
+	for key, value := range want {
+		if entry[key] != value {
+			t.Errorf("Middleware() %s = %v, want %v", key, entry[key], value)
+		}
+	}
+	if bytes.Contains(lines[1], []byte("secret")) {
+		t.Errorf("Middleware() logged the query string: %s", lines[1])
+	}
+	if _, ok := entry["upstream_latency_ms"]; !ok {
+		t.Errorf("Middleware() did not log upstream_latency_ms")
+	}
+}
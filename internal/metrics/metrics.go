@@ -0,0 +1,223 @@
+/*
+Copyright © 2020 Andy Lo-A-Foe
+
+Permission is hereby granted, free of charge, to any person obtaining a copy
+of this software and associated documentation files (the "Software"), to deal
+in the Software without restriction, including without limitation the rights
+to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+copies of the Software, and to permit persons to whom the Software is
+furnished to do so, subject to the following conditions:
+
+The above copyright notice and this permission notice shall be included in
+all copies or substantial portions of the Software.
+
+THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+THE SOFTWARE.
+*/
+package metrics
+
+import (
+	"net/http"
+	"strconv"
+	"time"
+
+	"terraform-registry/internal/download"
+
+	"github.com/labstack/echo/v4"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/collectors"
+	"github.com/prometheus/client_golang/prometheus/promhttp"
+)
+
+// Path is the path the metrics are served at
+const Path = "/metrics"
+
+const namespace = "terraform_registry"
+
+// Metrics collects the Prometheus metrics of the registry. A nil Metrics records nothing.
+type Metrics struct {
+	registry *prometheus.Registry
+
+	requests         *prometheus.CounterVec
+	requestDuration  *prometheus.HistogramVec
+	upstreamRequests *prometheus.CounterVec
+	upstreamDuration *prometheus.HistogramVec
+	rateLimit        prometheus.Gauge
+	downloads        *prometheus.CounterVec
+	reloads          *prometheus.CounterVec
+	lastReload       prometheus.Gauge
+}
+
+// New creates a Metrics with its own registry, including the Go runtime and process metrics
+func New() *Metrics {
+	m := &Metrics{
+		registry: prometheus.NewRegistry(),
+		requests: prometheus.NewCounterVec(prometheus.CounterOpts{
+			Namespace: namespace,
+			Name:      "http_requests_total",
+			Help:      "HTTP requests handled, by route, method and status.",
+		}, []string{"route", "method", "status"}),
+		requestDuration: prometheus.NewHistogramVec(prometheus.HistogramOpts{
+			Namespace: namespace,
+			Name:      "http_request_duration_seconds",
+			Help:      "Duration of HTTP requests, by route, method and status.",
+			Buckets:   prometheus.DefBuckets,
+		}, []string{"route", "method", "status"}),
+		upstreamRequests: prometheus.NewCounterVec(prometheus.CounterOpts{
+			Namespace: namespace,
+			Name:      "upstream_requests_total",
+			Help:      "Requests to the GitHub API and asset downloads, by target and status.",
+		}, []string{"target", "status"}),
+		upstreamDuration: prometheus.NewHistogramVec(prometheus.HistogramOpts{
+			Namespace: namespace,
+			Name:      "upstream_request_duration_seconds",
+			Help:      "Duration of requests to the GitHub API and asset downloads until the response headers, by target and status.",
+			Buckets:   prometheus.DefBuckets,
+		}, []string{"target", "status"}),
+		rateLimit: prometheus.NewGauge(prometheus.GaugeOpts{
+			Namespace: namespace,
+			Name:      "github_rate_limit_remaining",
+			Help:      "Requests left in the current GitHub API rate limit window.",
+		}),
+		downloads: prometheus.NewCounterVec(prometheus.CounterOpts{
+			Namespace: namespace,
+			Name:      "provider_downloads_total",
+			Help:      "Provider downloads handed out, by namespace, type, version and platform.",
+		}, []string{"namespace", "type", "version", "platform"}),
+		reloads: prometheus.NewCounterVec(prometheus.CounterOpts{
+			Namespace: namespace,
+			Name:      "config_reloads_total",
+			Help:      "Configuration reloads, by result.",
+		}, []string{"result"}),
+		lastReload: prometheus.NewGauge(prometheus.GaugeOpts{
+			Namespace: namespace,
+			Name:      "config_last_reload_success_timestamp_seconds",
+			Help:      "Time of the last successful configuration reload.",
+		}),
+	}
+	m.registry.MustRegister(
+		collectors.NewGoCollector(),
+		collectors.NewProcessCollector(collectors.ProcessCollectorOpts{}),
+		m.requests,
+		m.requestDuration,
+		m.upstreamRequests,
+		m.upstreamDuration,
+		m.rateLimit,
+		m.downloads,
+		m.reloads,
+		m.lastReload,
+	)
+	return m
+}
+
+// Handler returns the handler serving the metrics in the Prometheus exposition format
+func (m *Metrics) Handler() echo.HandlerFunc {
+	return echo.WrapHandler(promhttp.HandlerFor(m.registry, promhttp.HandlerOpts{}))
+}
+
+// Middleware records the count and duration of requests by route
+func (m *Metrics) Middleware() echo.MiddlewareFunc {
+	return func(next echo.HandlerFunc) echo.HandlerFunc {
+		return func(c echo.Context) error {
+			start := time.Now()
+			err := next(c)
+			status := c.Response().Status
+			if err != nil {
+				status = http.StatusInternalServerError
+				if httpErr, ok := err.(*echo.HTTPError); ok {
+					status = httpErr.Code
+				}
+			}
+			route := c.Path()
+			if route == "" {
+				route = "unmatched"
+			}
+			labels := prometheus.Labels{
+				"route":  route,
+				"method": c.Request().Method,
+				"status": strconv.Itoa(status),
+			}
+			m.requests.With(labels).Inc()
+			m.requestDuration.With(labels).Observe(time.Since(start).Seconds())
+			return err
+		}
+	}
+}
+
+// Upstream request targets
+const (
+	// GitHub are requests to the GitHub API
+	GitHub = "github"
+	// Download are downloads of release assets and upstream registry requests
+	Download = "download"
+)
+
+// Transport wraps base, recording the count and duration of the requests to target and
+// the remaining GitHub rate limit. A nil base uses http.DefaultTransport.
+func (m *Metrics) Transport(target string, base http.RoundTripper) http.RoundTripper {
+	if base == nil {
+		base = http.DefaultTransport
+	}
+	return download.RoundTripperFunc(func(req *http.Request) (*http.Response, error) {
+		start := time.Now()
+		resp, err := base.RoundTrip(req)
+		status := "error"
+		if err == nil {
+			status = strconv.Itoa(resp.StatusCode)
+			if remaining, err := strconv.Atoi(resp.Header.Get("X-RateLimit-Remaining")); err == nil {
+				m.rateLimit.Set(float64(remaining))
+			}
+		}
+		m.upstreamRequests.WithLabelValues(target, status).Inc()
+		m.upstreamDuration.WithLabelValues(target, status).Observe(time.Since(start).Seconds())
+		return resp, err
+	})
+}
+
+// RegisterCache exposes the hits and misses reported by stats as the lookups of the named cache
+func (m *Metrics) RegisterCache(name string, stats func() (hits, misses uint64)) {
+	opts := func(result string) prometheus.CounterOpts {
+		return prometheus.CounterOpts{
+			Namespace:   namespace,
+			Name:        "cache_lookups_total",
+			Help:        "Cache lookups, by cache and result.",
+			ConstLabels: prometheus.Labels{"cache": name, "result": result},
+		}
+	}
+	m.registry.MustRegister(
+		prometheus.NewCounterFunc(opts("hit"), func() float64 {
+			hits, _ := stats()
+			return float64(hits)
+		}),
+		prometheus.NewCounterFunc(opts("miss"), func() float64 {
+			_, misses := stats()
+			return float64(misses)
+		}),
+	)
+}
+
+// Download records a provider download handed out for a platform
+func (m *Metrics) Download(namespace, typeParam, version, os, arch string) {
+	if m == nil {
+		return
+	}
+	m.downloads.WithLabelValues(namespace, typeParam, version, os+"_"+arch).Inc()
+}
+
+// Reload records a configuration reload, failed when err is not nil
+func (m *Metrics) Reload(err error) {
+	if m == nil {
+		return
+	}
+	if err != nil {
+		m.reloads.WithLabelValues("failure").Inc()
+		return
+	}
+	m.reloads.WithLabelValues("success").Inc()
+	m.lastReload.SetToCurrentTime()
+}
@@ -0,0 +1,135 @@
+/*
+Copyright © 2020 Andy Lo-A-Foe
+
+Permission is hereby granted, free of charge, to any person obtaining a copy
+of this software and associated documentation files (the "Software"), to deal
+in the Software without restriction, including without limitation the rights
+to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+copies of the Software, and to permit persons to whom the Software is
+furnished to do so, subject to the following conditions:
+
+The above copyright notice and this permission notice shall be included in
+all copies or substantial portions of the Software.
+
+THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+THE SOFTWARE.
+*/
+package metrics
+
+import (
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"terraform-registry/internal/download"
+
+	"github.com/labstack/echo/v4"
+	"github.com/prometheus/client_golang/prometheus/testutil"
+)
+
+func TestMiddleware(t *testing.T) {
+	m := New()
+	e := echo.New()
+	e.Use(m.Middleware())
+	e.GET("/v1/providers/:namespace/:type/*", func(c echo.Context) error {
+		return c.String(http.StatusOK, "ok")
+	})
+	e.GET("/fail", func(c echo.Context) error {
+		return echo.NewHTTPError(http.StatusBadGateway)
+	})
+
+	for _, target := range []string{"/v1/providers/philips/hsdp/versions", "/v1/providers/philips/hsdp/1.0.0", "/fail"} {
+		e.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest(http.MethodGet, target, nil))
+	}
+
+	tests := []struct {
+		name   string
+		route  string
+		status string
+		want   float64
+	}{
+		{name: "Provider route", route: "/v1/providers/:namespace/:type/*", status: "200", want: 2},
+		{name: "Failing route", route: "/fail", status: "502", want: 1},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := testutil.ToFloat64(m.requests.WithLabelValues(tt.route, http.MethodGet, tt.status))
+			if got != tt.want {
+				t.Errorf("requests = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestTransport(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("X-RateLimit-Remaining", "4999")
+		w.WriteHeader(http.StatusNotFound)
+	}))
+	defer server.Close()
+
+	m := New()
+	client := &http.Client{Transport: m.Transport(GitHub, nil)}
+	resp, err := client.Get(server.URL)
+	if err != nil {
+		t.Fatalf("Get() error = %v", err)
+	}
+	_ = resp.Body.Close()
+
+	if got := testutil.ToFloat64(m.upstreamRequests.WithLabelValues(GitHub, "404")); got != 1 {
+		t.Errorf("github requests = %v, want 1", got)
+	}
+	if got := testutil.ToFloat64(m.rateLimit); got != 4999 {
+		t.Errorf("rate limit remaining = %v, want 4999", got)
+	}
+
+	failing := &http.Client{Transport: m.Transport(Download, download.RoundTripperFunc(func(*http.Request) (*http.Response, error) {
+		return nil, errors.New("connection refused")
+	}))}
+	if _, err := failing.Get(server.URL); err == nil {
+		t.Error("Get() expected error")
+	}
+	if got := testutil.ToFloat64(m.upstreamRequests.WithLabelValues(Download, "error")); got != 1 {
+		t.Errorf("failed downloads = %v, want 1", got)
+	}
+}
+
+func TestHandler(t *testing.T) {
+	m := New()
+	m.RegisterCache("releases", func() (uint64, uint64) { return 3, 1 })
+	m.Download("philips", "hsdp", "1.0.0", "linux", "amd64")
+	m.Reload(nil)
+	m.Reload(errors.New("invalid config"))
+
+	e := echo.New()
+	e.GET(Path, m.Handler())
+	rec := httptest.NewRecorder()
+	e.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, Path, nil))
+	if rec.Code != http.StatusOK {
+		t.Fatalf("Expected status code %d, got %d", http.StatusOK, rec.Code)
+	}
+	body := rec.Body.String()
+	for _, want := range []string{
+		`terraform_registry_cache_lookups_total{cache="releases",result="hit"} 3`,
+		`terraform_registry_cache_lookups_total{cache="releases",result="miss"} 1`,
+		`terraform_registry_provider_downloads_total{namespace="philips",platform="linux_amd64",type="hsdp",version="1.0.0"} 1`,
+		`terraform_registry_config_reloads_total{result="success"} 1`,
+		`terraform_registry_config_reloads_total{result="failure"} 1`,
+		`go_goroutines`,
+	} {
+		if !strings.Contains(body, want) {
+			t.Errorf("Expected metrics to contain %s", want)
+		}
+	}
+
+	var disabled *Metrics
+	disabled.Download("philips", "hsdp", "1.0.0", "linux", "amd64")
+	disabled.Reload(nil)
+}
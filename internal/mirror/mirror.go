@@ -0,0 +1,211 @@
+/*
+Copyright © 2020 Andy Lo-A-Foe
+
+Permission is hereby granted, free of charge, to any person obtaining a copy
+of this software and associated documentation files (the "Software"), to deal
+in the Software without restriction, including without limitation the rights
+to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+copies of the Software, and to permit persons to whom the Software is
+furnished to do so, subject to the following conditions:
+
+The above copyright notice and this permission notice shall be included in
+all copies or substantial portions of the Software.
+
+THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+THE SOFTWARE.
+*/
+package mirror
+
+import (
+	"context"
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"strconv"
+	"sync"
+	"time"
+
+	"terraform-registry/internal/download"
+	"terraform-registry/internal/storage"
+)
+
+// PathPrefix is the path mirrored artifacts are served below
+const PathPrefix = "/mirror/"
+
+// urlTTL is how long signed artifact URLs stay valid
+const urlTTL = 15 * time.Minute
+
+// Artifact identifies a file of a provider release
+type Artifact struct {
+	Namespace string
+	Type      string
+	Version   string
+	Filename  string
+}
+
+func (a Artifact) key() string {
+	return a.Namespace + "/" + a.Type + "/" + a.Version + "/" + a.Filename
+}
+
+// Mirror keeps copies of release artifacts in a store, so downloads no longer
+// depend on GitHub once an artifact was fetched
+type Mirror struct {
+	store  storage.Store
+	secret []byte
+	client *http.Client
+	now    func() time.Time
+
+	mu       sync.Mutex
+	inflight map[string]*fetch
+
+	background sync.WaitGroup
+}
+
+type fetch struct {
+	done chan struct{}
+	err  error
+}
+
+// New creates a Mirror on top of store, fetching artifacts with client. Artifact URLs
+// are signed with secret, a random one is generated when it is empty.
+func New(store storage.Store, secret []byte, client *http.Client) *Mirror {
+	if len(secret) == 0 {
+		secret = make([]byte, 32)
+		_, _ = rand.Read(secret)
+	}
+	return &Mirror{
+		store:    store,
+		secret:   secret,
+		client:   client,
+		now:      time.Now,
+		inflight: make(map[string]*fetch),
+	}
+}
+
+// Has reports whether all artifacts are mirrored
+func (m *Mirror) Has(artifacts ...Artifact) bool {
+	for _, a := range artifacts {
+		if !m.store.Exists(a.key()) {
+			return false
+		}
+	}
+	return true
+}
+
+// Open returns the contents of a mirrored artifact
+func (m *Mirror) Open(a Artifact) (io.ReadCloser, error) {
+	return m.store.Open(a.key())
+}
+
+// Fetch downloads the artifact from url into the store, unless it is mirrored already.
+// When shasum is set the contents are verified against it and nothing is stored on a
+// mismatch. Concurrent fetches of the same artifact share a single download.
+func (m *Mirror) Fetch(ctx context.Context, a Artifact, url, shasum string) error {
+	key := a.key()
+	m.mu.Lock()
+	if f, ok := m.inflight[key]; ok {
+		m.mu.Unlock()
+		<-f.done
+		return f.err
+	}
+	if m.store.Exists(key) {
+		m.mu.Unlock()
+		return nil
+	}
+	f := &fetch{done: make(chan struct{})}
+	m.inflight[key] = f
+	m.mu.Unlock()
+
+	f.err = m.fetch(ctx, key, url, shasum)
+
+	m.mu.Lock()
+	delete(m.inflight, key)
+	m.mu.Unlock()
+	close(f.done)
+	return f.err
+}
+
+// Go runs fn in the background, Wait waits for it to finish
+func (m *Mirror) Go(fn func()) {
+	m.background.Add(1)
+	go func() {
+		defer m.background.Done()
+		fn()
+	}()
+}
+
+// Wait waits for the functions started with Go, giving up when ctx is done
+func (m *Mirror) Wait(ctx context.Context) error {
+	if m == nil {
+		return nil
+	}
+	done := make(chan struct{})
+	go func() {
+		m.background.Wait()
+		close(done)
+	}()
+	select {
+	case <-done:
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
+func (m *Mirror) fetch(ctx context.Context, key, url, shasum string) error {
+	body, err := download.Open(ctx, m.client, url)
+	if err != nil {
+		return fmt.Errorf("fetching %s: %w", key, err)
+	}
+	defer func() { _ = body.Close() }()
+
+	var r io.Reader = body
+	if shasum != "" {
+		r = download.VerifyingReader(body, shasum)
+	}
+	if err := m.store.Put(key, r); err != nil {
+		return fmt.Errorf("storing %s: %w", key, err)
+	}
+	return nil
+}
+
+// URL returns a signed, expiring URL path the registry serves the artifact from
+func (m *Mirror) URL(a Artifact) string {
+	expires := strconv.FormatInt(m.now().Add(urlTTL).Unix(), 10)
+	query := url.Values{
+		"expires":   {expires},
+		"signature": {m.sign(a, expires)},
+	}
+	return PathPrefix + a.key() + "?" + query.Encode()
+}
+
+// Verify checks the expiry and signature of an artifact URL
+func (m *Mirror) Verify(a Artifact, expires, signature string) error {
+	unix, err := strconv.ParseInt(expires, 10, 64)
+	if err != nil {
+		return fmt.Errorf("invalid expiry")
+	}
+	if m.now().Unix() > unix {
+		return fmt.Errorf("url expired")
+	}
+	if !hmac.Equal([]byte(signature), []byte(m.sign(a, expires))) {
+		return fmt.Errorf("invalid signature")
+	}
+	return nil
+}
+
+func (m *Mirror) sign(a Artifact, expires string) string {
+	mac := hmac.New(sha256.New, m.secret)
+	_, _ = mac.Write([]byte(a.key() + "\n" + expires))
+	return hex.EncodeToString(mac.Sum(nil))
+}
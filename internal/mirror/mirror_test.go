@@ -0,0 +1,168 @@
+/*
+Copyright © 2020 Andy Lo-A-Foe
+
+Permission is hereby granted, free of charge, to any person obtaining a copy
+of this software and associated documentation files (the "Software"), to deal
+in the Software without restriction, including without limitation the rights
+to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+copies of the Software, and to permit persons to whom the Software is
+furnished to do so, subject to the following conditions:
+
+The above copyright notice and this permission notice shall be included in
+all copies or substantial portions of the Software.
+
+THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+THE SOFTWARE.
+*/
+package mirror
+
+import (
+	"context"
+	"crypto/sha256"
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"terraform-registry/internal/storage"
+)
+
+var testArtifact = Artifact{
+	Namespace: "philips-labs",
+	Type:      "hsdp",
+	Version:   "1.0.0",
+	Filename:  "terraform-provider-hsdp_1.0.0_linux_amd64.zip",
+}
+
+func newTestMirror(t *testing.T) *Mirror {
+	store, err := storage.NewLocal(t.TempDir())
+	if err != nil {
+		t.Fatalf("NewLocal() error = %v", err)
+	}
+	return New(store, []byte("secret"), nil)
+}
+
+func TestFetch(t *testing.T) {
+	content := "zip content"
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		_, _ = w.Write([]byte(content))
+	}))
+	defer server.Close()
+
+	tests := []struct {
+		name    string
+		shasum  string
+		wantErr bool
+	}{
+		{name: "Without shasum", shasum: ""},
+		{name: "Matching shasum", shasum: fmt.Sprintf("%x", sha256.Sum256([]byte(content)))},
+		{name: "Mismatching shasum", shasum: fmt.Sprintf("%x", sha256.Sum256([]byte("other"))), wantErr: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			m := newTestMirror(t)
+			err := m.Fetch(context.Background(), testArtifact, server.URL, tt.shasum)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("Fetch() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if m.Has(testArtifact) == tt.wantErr {
+				t.Errorf("Has() = %v, want %v", m.Has(testArtifact), !tt.wantErr)
+			}
+			if tt.wantErr {
+				return
+			}
+			r, _ := m.Open(testArtifact)
+			data, _ := io.ReadAll(r)
+			_ = r.Close()
+			if string(data) != content {
+				t.Errorf("Open() content = %q, want %q", data, content)
+			}
+		})
+	}
+}
+
+func TestFetchDeduplicates(t *testing.T) {
+	var requests int32
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		atomic.AddInt32(&requests, 1)
+		time.Sleep(20 * time.Millisecond)
+		_, _ = w.Write([]byte("content"))
+	}))
+	defer server.Close()
+
+	m := newTestMirror(t)
+	var wg sync.WaitGroup
+	for i := 0; i < 5; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			if err := m.Fetch(context.Background(), testArtifact, server.URL, ""); err != nil {
+				t.Errorf("Fetch() error = %v", err)
+			}
+		}()
+	}
+	wg.Wait()
+	_ = m.Fetch(context.Background(), testArtifact, server.URL, "")
+
+	if requests != 1 {
+		t.Errorf("Expected 1 download, got %d", requests)
+	}
+}
+
+func TestWait(t *testing.T) {
+	m := newTestMirror(t)
+	release := make(chan struct{})
+	var finished atomic.Bool
+	m.Go(func() {
+		<-release
+		finished.Store(true)
+	})
+
+	ctx, cancel := context.WithTimeout(context.Background(), 20*time.Millisecond)
+	defer cancel()
+	if err := m.Wait(ctx); err == nil {
+		t.Error("Wait() returned before the background work finished")
+	}
+	close(release)
+	if err := m.Wait(context.Background()); err != nil || !finished.Load() {
+		t.Errorf("Wait() error = %v, finished = %v", err, finished.Load())
+	}
+}
+
+func TestURL(t *testing.T) {
+	m := newTestMirror(t)
+	signed, err := url.Parse(m.URL(testArtifact))
+	if err != nil {
+		t.Fatalf("URL() returned invalid URL: %v", err)
+	}
+	if !strings.HasPrefix(signed.Path, PathPrefix+"philips-labs/hsdp/1.0.0/") {
+		t.Errorf("URL() path = %s", signed.Path)
+	}
+	expires := signed.Query().Get("expires")
+	signature := signed.Query().Get("signature")
+
+	if err := m.Verify(testArtifact, expires, signature); err != nil {
+		t.Errorf("Verify() error = %v", err)
+	}
+	other := testArtifact
+	other.Version = "2.0.0"
+	if err := m.Verify(other, expires, signature); err == nil {
+		t.Error("Verify() accepted signature of another artifact")
+	}
+	m.now = func() time.Time { return time.Now().Add(time.Hour) }
+	if err := m.Verify(testArtifact, expires, signature); err == nil {
+		t.Error("Verify() accepted an expired URL")
+	}
+}
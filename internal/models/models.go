@@ -21,7 +21,11 @@ THE SOFTWARE.
 */
 package models
 
-import "github.com/google/go-github/v32/github"
+import (
+	"time"
+
+	"github.com/google/go-github/v32/github"
+)
 
 // Platform represents an OS and architecture combination
 type Platform struct {
@@ -77,7 +81,164 @@ type Version struct {
 	ReleaseAsset *github.ReleaseAsset `json:"-"`
 }
 
+// ProviderSummary describes a provider hosted by the registry
+type ProviderSummary struct {
+	ID            string `json:"id"`
+	Namespace     string `json:"namespace"`
+	Name          string `json:"name"`
+	Description   string `json:"description"`
+	Source        string `json:"source"`
+	LatestVersion string `json:"latest_version,omitempty"`
+	Platforms     int    `json:"platforms"`
+}
+
+// ProviderListResponse represents the response structure for provider listings and searches
+type ProviderListResponse struct {
+	Providers []ProviderSummary `json:"providers"`
+}
+
+// VersionMetadata describes a single provider version from its GitHub release
+type VersionMetadata struct {
+	ID          string     `json:"id"`
+	Namespace   string     `json:"namespace"`
+	Name        string     `json:"name"`
+	Version     string     `json:"version"`
+	Tag         string     `json:"tag"`
+	PublishedAt *time.Time `json:"published_at,omitempty"`
+	Description string     `json:"description"`
+	Source      string     `json:"source"`
+	Platforms   []Platform `json:"platforms"`
+	// SigningKeys are the IDs of the GPG keys the release is signed with
+	SigningKeys []string `json:"signing_keys"`
+}
+
+// ReleaseNotes are the release notes of a provider version
+type ReleaseNotes struct {
+	Version     string     `json:"version"`
+	Tag         string     `json:"tag"`
+	PublishedAt *time.Time `json:"published_at,omitempty"`
+	URL         string     `json:"url"`
+	Notes       string     `json:"notes"`
+}
+
+// ChangelogResponse lists the release notes of the versions after From up to To, newest first
+type ChangelogResponse struct {
+	ID       string         `json:"id"`
+	From     string         `json:"from,omitempty"`
+	To       string         `json:"to,omitempty"`
+	Releases []ReleaseNotes `json:"releases"`
+}
+
+// DocAttributes are the attributes of a provider documentation page
+type DocAttributes struct {
+	Category    string `json:"category"`
+	Slug        string `json:"slug"`
+	Title       string `json:"title"`
+	Subcategory string `json:"subcategory,omitempty"`
+	Path        string `json:"path"`
+	Language    string `json:"language"`
+	Content     string `json:"content,omitempty"`
+}
+
+// DocResource represents a provider documentation page in the style of the v2 registry API
+type DocResource struct {
+	Type       string        `json:"type"`
+	ID         string        `json:"id"`
+	Attributes DocAttributes `json:"attributes"`
+}
+
+// DocsListResponse represents the response structure for documentation listings
+type DocsListResponse struct {
+	Data []DocResource `json:"data"`
+}
+
+// DocResponse represents the response structure for a documentation page
+type DocResponse struct {
+	Data DocResource `json:"data"`
+}
+
+// PlatformMatrixVersion lists the os_arch platforms a version has and misses
+type PlatformMatrixVersion struct {
+	Version   string   `json:"version"`
+	Platforms []string `json:"platforms"`
+	Missing   []string `json:"missing"`
+}
+
+// PlatformMatrix represents the platform availability of all versions of a provider,
+// Platforms being every os_arch platform found in any version
+type PlatformMatrix struct {
+	ID        string                  `json:"id"`
+	Platforms []string                `json:"platforms"`
+	Versions  []PlatformMatrixVersion `json:"versions"`
+}
+
+// ResolveResponse lists the versions matching a version constraint, newest first
+type ResolveResponse struct {
+	ID         string   `json:"id"`
+	Constraint string   `json:"constraint"`
+	Latest     string   `json:"latest"`
+	Versions   []string `json:"versions"`
+}
+
+// HashesResponse lists the lock file hashes of a provider version per os_arch platform
+type HashesResponse struct {
+	ID      string              `json:"id"`
+	Version string              `json:"version"`
+	Hashes  map[string][]string `json:"hashes"`
+}
+
+// LockProvider is a provider and version constraint to generate a lock file entry for
+type LockProvider struct {
+	Source  string `json:"source"`
+	Version string `json:"version"`
+}
+
+// LockRequest represents the request for lock file entries of providers on a set of
+// os_arch platforms
+type LockRequest struct {
+	Providers []LockProvider `json:"providers"`
+	Platforms []string       `json:"platforms"`
+}
+
+// NetworkMirrorIndex represents the network mirror protocol list of available versions
+type NetworkMirrorIndex struct {
+	Versions map[string]struct{} `json:"versions"`
+}
+
+// NetworkMirrorArchive represents a platform zip in the network mirror protocol
+type NetworkMirrorArchive struct {
+	URL    string   `json:"url"`
+	Hashes []string `json:"hashes,omitempty"`
+}
+
+// NetworkMirrorVersion represents the network mirror protocol list of platform zips of a version
+type NetworkMirrorVersion struct {
+	Archives map[string]NetworkMirrorArchive `json:"archives"`
+}
+
+// LoginV1 represents the login.v1 service discovery entry
+type LoginV1 struct {
+	Client     string   `json:"client"`
+	GrantTypes []string `json:"grant_types"`
+	Authz      string   `json:"authz"`
+	Token      string   `json:"token"`
+	Ports      []int    `json:"ports"`
+}
+
 // ServiceDiscoveryResponse represents the service discovery response
 type ServiceDiscoveryResponse struct {
-	Providers string `json:"providers.v1"`
+	Providers string   `json:"providers.v1"`
+	Login     *LoginV1 `json:"login.v1,omitempty"`
+}
+
+// HealthCheck represents the outcome of a single readiness check
+type HealthCheck struct {
+	Status  string `json:"status"`
+	Message string `json:"message,omitempty"`
+}
+
+// HealthResponse represents the response of the health endpoints
+type HealthResponse struct {
+	Status string                 `json:"status"`
+	Checks map[string]HealthCheck `json:"checks,omitempty"`
 }
@@ -31,9 +31,11 @@ import (
 )
 
 var (
-	shasumRegexp = regexp.MustCompile(`^(?P<provider>[^_]+)_(?P<version>[^_]+)_SHA256SUMS`)
-	binaryRegexp = regexp.MustCompile(`^(?P<provider>[^_]+)_(?P<version>[^_]+)_(?P<os>\w+)_(?P<arch>\w+)`)
-	ActionRegexp = regexp.MustCompile(`^(?P<version>[^/]+)/(?P<action>[^/]+)/(?P<os>[^/]+)/(?P<arch>\w+)`)
+	shasumRegexp  = regexp.MustCompile(`^(?P<provider>[^_]+)_(?P<version>[^_]+)_SHA256SUMS`)
+	binaryRegexp  = regexp.MustCompile(`^(?P<provider>[^_]+)_(?P<version>[^_]+)_(?P<os>\w+)_(?P<arch>\w+)`)
+	ActionRegexp  = regexp.MustCompile(`^(?P<version>[^/]+)/(?P<action>[^/]+)/(?P<os>[^/]+)/(?P<arch>\w+)`)
+	HashesRegexp  = regexp.MustCompile(`^(?P<version>[^/]+)/hashes$`)
+	VersionRegexp = regexp.MustCompile(`^(?P<version>[0-9][^/]*)$`)
 )
 
 // ParseVersions extracts version information from GitHub releases
@@ -285,6 +285,33 @@ func TestActionRegexp(t *testing.T) {
 	}
 }
 
+func TestVersionRegexp(t *testing.T) {
+	tests := []struct {
+		name        string
+		input       string
+		wantMatch   bool
+		wantVersion string
+	}{
+		{name: "Version", input: "1.0.0", wantMatch: true, wantVersion: "1.0.0"},
+		{name: "Prerelease", input: "2.10.0-beta.1", wantMatch: true, wantVersion: "2.10.0-beta.1"},
+		{name: "Versions listing", input: "versions", wantMatch: false},
+		{name: "Action", input: "1.0.0/download/linux/amd64", wantMatch: false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			match := VersionRegexp.FindStringSubmatch(tt.input)
+			if (len(match) >= 2) != tt.wantMatch {
+				t.Errorf("VersionRegexp match = %v, wantMatch %v", len(match) >= 2, tt.wantMatch)
+				return
+			}
+			if tt.wantMatch && match[1] != tt.wantVersion {
+				t.Errorf("VersionRegexp version = %v, want %v", match[1], tt.wantVersion)
+			}
+		})
+	}
+}
+
 // Helper function to create string pointers
 func stringPtr(s string) *string {
 	return &s
@@ -0,0 +1,82 @@
+/*
+Copyright © 2020 Andy Lo-A-Foe
+
+Permission is hereby granted, free of charge, to any person obtaining a copy
+of this software and associated documentation files (the "Software"), to deal
+in the Software without restriction, including without limitation the rights
+to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+copies of the Software, and to permit persons to whom the Software is
+furnished to do so, subject to the following conditions:
+
+The above copyright notice and this permission notice shall be included in
+all copies or substantial portions of the Software.
+
+THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+THE SOFTWARE.
+*/
+package parser
+
+import (
+	"fmt"
+	"sort"
+
+	"terraform-registry/internal/models"
+
+	"github.com/hashicorp/go-version"
+)
+
+// SortVersions sorts versions newest first. Versions which are not valid semver
+// are sorted last, in their original order.
+func SortVersions(versions []models.Version) {
+	parsed := make(map[string]*version.Version, len(versions))
+	for _, v := range versions {
+		if sv, err := version.NewVersion(v.Version); err == nil {
+			parsed[v.Version] = sv
+		}
+	}
+	sort.SliceStable(versions, func(i, j int) bool {
+		a, b := parsed[versions[i].Version], parsed[versions[j].Version]
+		switch {
+		case a == nil:
+			return false
+		case b == nil:
+			return true
+		}
+		return a.GreaterThan(b)
+	})
+}
+
+// MatchVersions returns the versions satisfying a terraform version constraint such
+// as "~> 1.2", newest first. Prereleases only match constraints naming them, an empty
+// constraint matches all other versions.
+func MatchVersions(versions []models.Version, constraint string) ([]models.Version, error) {
+	var constraints version.Constraints
+	if constraint != "" {
+		var err error
+		constraints, err = version.NewConstraint(constraint)
+		if err != nil {
+			return nil, fmt.Errorf("invalid version constraint %q: %w", constraint, err)
+		}
+	}
+	matches := make([]models.Version, 0, len(versions))
+	for _, v := range versions {
+		sv, err := version.NewVersion(v.Version)
+		if err != nil {
+			continue
+		}
+		if constraints == nil && sv.Prerelease() != "" {
+			continue
+		}
+		if constraints != nil && !constraints.Check(sv) {
+			continue
+		}
+		matches = append(matches, v)
+	}
+	SortVersions(matches)
+	return matches, nil
+}
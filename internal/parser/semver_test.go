@@ -0,0 +1,89 @@
+/*
+Copyright © 2020 Andy Lo-A-Foe
+
+Permission is hereby granted, free of charge, to any person obtaining a copy
+of this software and associated documentation files (the "Software"), to deal
+in the Software without restriction, including without limitation the rights
+to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+copies of the Software, and to permit persons to whom the Software is
+furnished to do so, subject to the following conditions:
+
+The above copyright notice and this permission notice shall be included in
+all copies or substantial portions of the Software.
+
+THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+THE SOFTWARE.
+*/
+package parser
+
+import (
+	"reflect"
+	"testing"
+
+	"terraform-registry/internal/models"
+)
+
+func testVersions(versions ...string) []models.Version {
+	result := make([]models.Version, 0, len(versions))
+	for _, v := range versions {
+		result = append(result, models.Version{Version: v})
+	}
+	return result
+}
+
+func versionStrings(versions []models.Version) []string {
+	result := make([]string, 0, len(versions))
+	for _, v := range versions {
+		result = append(result, v.Version)
+	}
+	return result
+}
+
+func TestSortVersions(t *testing.T) {
+	versions := testVersions("0.9.0", "bogus", "1.10.0", "1.2.0", "1.10.0-beta1")
+	SortVersions(versions)
+	want := []string{"1.10.0", "1.10.0-beta1", "1.2.0", "0.9.0", "bogus"}
+	if got := versionStrings(versions); !reflect.DeepEqual(got, want) {
+		t.Errorf("SortVersions() = %v, want %v", got, want)
+	}
+}
+
+func TestMatchVersions(t *testing.T) {
+	versions := testVersions("0.9.0", "1.2.0", "1.2.5", "1.3.0", "2.0.0-rc1", "bogus")
+
+	tests := []struct {
+		name       string
+		constraint string
+		want       []string
+		wantErr    bool
+	}{
+		{"pessimistic minor", "~> 1.2", []string{"1.3.0", "1.2.5", "1.2.0"}, false},
+		{"pessimistic patch", "~> 1.2.0", []string{"1.2.5", "1.2.0"}, false},
+		{"range", ">= 0.9, < 1.2.5", []string{"1.2.0", "0.9.0"}, false},
+		{"exact", "= 1.2.0", []string{"1.2.0"}, false},
+		{"empty skips prereleases", "", []string{"1.3.0", "1.2.5", "1.2.0", "0.9.0"}, false},
+		{"exact prerelease", "2.0.0-rc1", []string{"2.0.0-rc1"}, false},
+		{"no match", "> 3.0", []string{}, false},
+		{"invalid", "~> one", nil, true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := MatchVersions(versions, tt.constraint)
+			if (err != nil) != tt.wantErr {
+				t.Errorf("MatchVersions() error = %v, wantErr %v", err, tt.wantErr)
+				return
+			}
+			if tt.wantErr {
+				return
+			}
+			if names := versionStrings(got); !reflect.DeepEqual(names, tt.want) {
+				t.Errorf("MatchVersions() = %v, want %v", names, tt.want)
+			}
+		})
+	}
+}
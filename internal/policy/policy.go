@@ -0,0 +1,214 @@
+/*
+Copyright © 2020 Andy Lo-A-Foe
+
+Permission is hereby granted, free of charge, to any person obtaining a copy
+of this software and associated documentation files (the "Software"), to deal
+in the Software without restriction, including without limitation the rights
+to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+copies of the Software, and to permit persons to whom the Software is
+furnished to do so, subject to the following conditions:
+
+The above copyright notice and this permission notice shall be included in
+all copies or substantial portions of the Software.
+
+THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+THE SOFTWARE.
+*/
+package policy
+
+import (
+	"fmt"
+	"os"
+	"path"
+
+	"terraform-registry/internal/auth"
+
+	"github.com/hashicorp/go-version"
+	"gopkg.in/yaml.v3"
+)
+
+const (
+	// Allow grants access
+	Allow = "allow"
+	// Deny refuses access
+	Deny = "deny"
+)
+
+// Rule grants or denies access to matching providers. Empty fields match everything.
+type Rule struct {
+	Name       string   `yaml:"name"`
+	Effect     string   `yaml:"effect"`
+	Subjects   []string `yaml:"subjects"`
+	Groups     []string `yaml:"groups"`
+	Namespaces []string `yaml:"namespaces"`
+	Providers  []string `yaml:"providers"`
+	Versions   string   `yaml:"versions"`
+	Prerelease *bool    `yaml:"prerelease"`
+
+	constraints version.Constraints
+}
+
+// Policy is the contents of a policy file
+type Policy struct {
+	Default string `yaml:"default"`
+	Rules   []Rule `yaml:"rules"`
+}
+
+// Request describes an access to a provider, or to one of its versions
+type Request struct {
+	Identity  *auth.Identity
+	Namespace string
+	Type      string
+	Version   string
+}
+
+// Decision is the outcome of evaluating a request
+type Decision struct {
+	Allowed bool
+	Reason  string
+}
+
+// Engine evaluates requests against a policy. Rules are evaluated in order and the
+// first matching rule decides, falling back to the policy default.
+// A nil Engine allows everything.
+type Engine struct {
+	policy Policy
+}
+
+// LoadFile reads and validates a policy file
+func LoadFile(filename string) (*Engine, error) {
+	data, err := os.ReadFile(filename)
+	if err != nil {
+		return nil, err
+	}
+	var p Policy
+	if err := yaml.Unmarshal(data, &p); err != nil {
+		return nil, fmt.Errorf("parsing policy %s: %w", filename, err)
+	}
+	return NewEngine(p)
+}
+
+// NewEngine validates the policy and creates a new Engine
+func NewEngine(p Policy) (*Engine, error) {
+	switch p.Default {
+	case "":
+		p.Default = Deny
+	case Allow, Deny:
+	default:
+		return nil, fmt.Errorf("invalid default effect %q", p.Default)
+	}
+	for i := range p.Rules {
+		rule := &p.Rules[i]
+		if rule.Name == "" {
+			rule.Name = fmt.Sprintf("#%d", i+1)
+		}
+		if rule.Effect != Allow && rule.Effect != Deny {
+			return nil, fmt.Errorf("rule %s: invalid effect %q", rule.Name, rule.Effect)
+		}
+		for _, patterns := range [][]string{rule.Subjects, rule.Groups, rule.Namespaces, rule.Providers} {
+			for _, pattern := range patterns {
+				if _, err := path.Match(pattern, ""); err != nil {
+					return nil, fmt.Errorf("rule %s: invalid pattern %q", rule.Name, pattern)
+				}
+			}
+		}
+		if rule.Versions != "" {
+			constraints, err := version.NewConstraint(rule.Versions)
+			if err != nil {
+				return nil, fmt.Errorf("rule %s: invalid versions: %w", rule.Name, err)
+			}
+			rule.constraints = constraints
+		}
+	}
+	return &Engine{policy: p}, nil
+}
+
+// Evaluate decides on the request. Without a version the provider itself is evaluated:
+// rules restricted to versions then only apply when they allow, since some versions
+// may still be accessible.
+func (e *Engine) Evaluate(req Request) Decision {
+	if e == nil {
+		return Decision{Allowed: true}
+	}
+	for _, rule := range e.policy.Rules {
+		if !rule.matches(req) {
+			continue
+		}
+		if rule.Effect == Allow {
+			return Decision{Allowed: true, Reason: fmt.Sprintf("rule %s allows %s", rule.Name, req)}
+		}
+		return Decision{Reason: fmt.Sprintf("rule %s denies %s", rule.Name, req)}
+	}
+	if e.policy.Default == Allow {
+		return Decision{Allowed: true, Reason: fmt.Sprintf("default allows %s", req)}
+	}
+	return Decision{Reason: fmt.Sprintf("no rule allows %s", req)}
+}
+
+func (r *Rule) matches(req Request) bool {
+	if len(r.Subjects) > 0 || len(r.Groups) > 0 {
+		if req.Identity == nil {
+			return false
+		}
+		if !matchAny(r.Subjects, req.Identity.Subject) && !matchAnyOf(r.Groups, req.Identity.Groups) {
+			return false
+		}
+	}
+	if len(r.Namespaces) > 0 && !matchAny(r.Namespaces, req.Namespace) {
+		return false
+	}
+	if len(r.Providers) > 0 && !matchAny(r.Providers, req.Type) {
+		return false
+	}
+	if r.constraints == nil && r.Prerelease == nil {
+		return true
+	}
+	if req.Version == "" {
+		return r.Effect == Allow
+	}
+	v, err := version.NewVersion(req.Version)
+	if err != nil {
+		return false
+	}
+	if r.Prerelease != nil && *r.Prerelease != (v.Prerelease() != "") {
+		return false
+	}
+	// Match on the core version so ranges also cover prereleases
+	return r.constraints == nil || r.constraints.Check(v.Core())
+}
+
+func matchAny(patterns []string, value string) bool {
+	for _, pattern := range patterns {
+		if ok, _ := path.Match(pattern, value); ok {
+			return true
+		}
+	}
+	return false
+}
+
+func matchAnyOf(patterns []string, values []string) bool {
+	for _, value := range values {
+		if matchAny(patterns, value) {
+			return true
+		}
+	}
+	return false
+}
+
+// String describes the request for decision reasons
+func (req Request) String() string {
+	subject := "anonymous"
+	if req.Identity != nil {
+		subject = req.Identity.Subject
+	}
+	target := req.Namespace + "/" + req.Type
+	if req.Version != "" {
+		target += " " + req.Version
+	}
+	return fmt.Sprintf("%s access to %s", subject, target)
+}
@@ -0,0 +1,168 @@
+/*
+Copyright © 2020 Andy Lo-A-Foe
+
+Permission is hereby granted, free of charge, to any person obtaining a copy
+of this software and associated documentation files (the "Software"), to deal
+in the Software without restriction, including without limitation the rights
+to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+copies of the Software, and to permit persons to whom the Software is
+furnished to do so, subject to the following conditions:
+
+The above copyright notice and this permission notice shall be included in
+all copies or substantial portions of the Software.
+
+THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+THE SOFTWARE.
+*/
+package policy
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"terraform-registry/internal/auth"
+)
+
+const testPolicy = `
+default: deny
+rules:
+  - name: platform-prereleases
+    effect: allow
+    groups: ["platform"]
+    namespaces: ["philips-*"]
+  - name: no-prereleases
+    effect: deny
+    prerelease: true
+  - name: old-hsdp
+    effect: deny
+    providers: ["hsdp"]
+    versions: "< 1.0.0"
+  - name: authenticated
+    effect: allow
+    subjects: ["*"]
+`
+
+func loadTestPolicy(t *testing.T) *Engine {
+	t.Helper()
+	filename := filepath.Join(t.TempDir(), "policy.yaml")
+	if err := os.WriteFile(filename, []byte(testPolicy), 0600); err != nil {
+		t.Fatal(err)
+	}
+	engine, err := LoadFile(filename)
+	if err != nil {
+		t.Fatalf("LoadFile() error = %v", err)
+	}
+	return engine
+}
+
+func TestEvaluate(t *testing.T) {
+	engine := loadTestPolicy(t)
+	platform := &auth.Identity{Subject: "alice", Groups: []string{"platform"}}
+	developer := &auth.Identity{Subject: "bob", Groups: []string{"developers"}}
+
+	tests := []struct {
+		name        string
+		request     Request
+		wantAllowed bool
+		wantRule    string
+	}{
+		{
+			name:        "Anonymous provider",
+			request:     Request{Namespace: "philips-labs", Type: "hsdp"},
+			wantAllowed: false,
+			wantRule:    "no rule",
+		},
+		{
+			name:        "Developer provider",
+			request:     Request{Identity: developer, Namespace: "philips-labs", Type: "hsdp"},
+			wantAllowed: true,
+			wantRule:    "authenticated",
+		},
+		{
+			name:        "Developer prerelease",
+			request:     Request{Identity: developer, Namespace: "philips-labs", Type: "hsdp", Version: "2.0.0-rc1"},
+			wantAllowed: false,
+			wantRule:    "no-prereleases",
+		},
+		{
+			name:        "Platform prerelease",
+			request:     Request{Identity: platform, Namespace: "philips-labs", Type: "hsdp", Version: "2.0.0-rc1"},
+			wantAllowed: true,
+			wantRule:    "platform-prereleases",
+		},
+		{
+			name:        "Developer old version",
+			request:     Request{Identity: developer, Namespace: "philips-labs", Type: "hsdp", Version: "0.9.1"},
+			wantAllowed: false,
+			wantRule:    "old-hsdp",
+		},
+		{
+			name:        "Developer old version of other provider",
+			request:     Request{Identity: developer, Namespace: "philips-labs", Type: "cloudfoundry", Version: "0.9.1"},
+			wantAllowed: true,
+			wantRule:    "authenticated",
+		},
+		{
+			name:        "Version restricted deny does not apply to provider",
+			request:     Request{Identity: developer, Namespace: "philips-labs", Type: "hsdp"},
+			wantAllowed: true,
+			wantRule:    "authenticated",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			decision := engine.Evaluate(tt.request)
+			if decision.Allowed != tt.wantAllowed {
+				t.Errorf("Evaluate() allowed = %v, want %v (%s)", decision.Allowed, tt.wantAllowed, decision.Reason)
+			}
+			if !strings.Contains(decision.Reason, tt.wantRule) {
+				t.Errorf("Evaluate() reason = %q, want mention of %q", decision.Reason, tt.wantRule)
+			}
+		})
+	}
+}
+
+func TestNilEngineAllows(t *testing.T) {
+	var engine *Engine
+	if !engine.Evaluate(Request{Namespace: "philips-labs", Type: "hsdp"}).Allowed {
+		t.Error("nil Engine should allow everything")
+	}
+}
+
+func TestDefaultAllow(t *testing.T) {
+	engine, err := NewEngine(Policy{Default: Allow})
+	if err != nil {
+		t.Fatalf("NewEngine() error = %v", err)
+	}
+	if !engine.Evaluate(Request{Namespace: "philips-labs", Type: "hsdp"}).Allowed {
+		t.Error("Evaluate() expected default allow")
+	}
+}
+
+func TestNewEngineValidation(t *testing.T) {
+	tests := []struct {
+		name   string
+		policy Policy
+	}{
+		{name: "Invalid default", policy: Policy{Default: "maybe"}},
+		{name: "Invalid effect", policy: Policy{Rules: []Rule{{Effect: "permit"}}}},
+		{name: "Invalid pattern", policy: Policy{Rules: []Rule{{Effect: Allow, Namespaces: []string{"["}}}}},
+		{name: "Invalid versions", policy: Policy{Rules: []Rule{{Effect: Allow, Versions: "~> banana"}}}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if _, err := NewEngine(tt.policy); err == nil {
+				t.Error("NewEngine() expected error, got nil")
+			}
+		})
+	}
+}
@@ -0,0 +1,97 @@
+/*
+Copyright © 2020 Andy Lo-A-Foe
+
+Permission is hereby granted, free of charge, to any person obtaining a copy
+of this software and associated documentation files (the "Software"), to deal
+in the Software without restriction, including without limitation the rights
+to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+copies of the Software, and to permit persons to whom the Software is
+furnished to do so, subject to the following conditions:
+
+The above copyright notice and this permission notice shall be included in
+all copies or substantial portions of the Software.
+
+THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+THE SOFTWARE.
+*/
+package storage
+
+import (
+	"errors"
+	"io"
+	"os"
+	"strings"
+	"testing"
+)
+
+func TestLocal(t *testing.T) {
+	store, err := NewLocal(t.TempDir())
+	if err != nil {
+		t.Fatalf("NewLocal() error = %v", err)
+	}
+	key := "philips-labs/hsdp/1.0.0/terraform-provider-hsdp_1.0.0_SHA256SUMS"
+
+	if store.Exists(key) {
+		t.Error("Exists() = true on empty store")
+	}
+	if _, err := store.Open(key); !os.IsNotExist(err) {
+		t.Errorf("Open() error = %v, want not exist", err)
+	}
+
+	if err := store.Put(key, strings.NewReader("content")); err != nil {
+		t.Fatalf("Put() error = %v", err)
+	}
+	if !store.Exists(key) {
+		t.Error("Exists() = false after Put()")
+	}
+	r, err := store.Open(key)
+	if err != nil {
+		t.Fatalf("Open() error = %v", err)
+	}
+	data, _ := io.ReadAll(r)
+	_ = r.Close()
+	if string(data) != "content" {
+		t.Errorf("Open() content = %q, want content", data)
+	}
+
+	if err := store.Delete(key); err != nil {
+		t.Errorf("Delete() error = %v", err)
+	}
+	if store.Exists(key) {
+		t.Error("Exists() = true after Delete()")
+	}
+}
+
+type failingReader struct{}
+
+func (failingReader) Read([]byte) (int, error) {
+	return 0, errors.New("broken")
+}
+
+func TestLocalPutFailure(t *testing.T) {
+	store, _ := NewLocal(t.TempDir())
+	if err := store.Put("a/b", failingReader{}); err == nil {
+		t.Fatal("Put() expected error, got nil")
+	}
+	if store.Exists("a/b") {
+		t.Error("Exists() = true after failed Put()")
+	}
+}
+
+func TestLocalInvalidKeys(t *testing.T) {
+	store, _ := NewLocal(t.TempDir())
+	keys := []string{"", "../escape", "a/../../b", "a//b", `a\b`, "/absolute"}
+
+	for _, key := range keys {
+		t.Run(key, func(t *testing.T) {
+			if err := store.Put(key, strings.NewReader("x")); err == nil {
+				t.Errorf("Put(%q) expected error, got nil", key)
+			}
+		})
+	}
+}
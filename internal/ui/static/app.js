@@ -0,0 +1,183 @@
+// Web UI for browsing the providers hosted by the registry. Everything shown is
+// fetched from the JSON API of the registry itself.
+(function () {
+  "use strict";
+
+  const content = document.getElementById("content");
+  const host = window.location.host;
+
+  function el(tag, attrs, ...children) {
+    const node = document.createElement(tag);
+    Object.entries(attrs || {}).forEach(([key, value]) => node.setAttribute(key, value));
+    children.forEach((child) => node.append(child));
+    return node;
+  }
+
+  // With terraform login enabled the API requires the registry token terraform login
+  // obtained. It is kept for the browser session only.
+  const tokenKey = "registry-token";
+
+  class Unauthorized extends Error {}
+
+  async function api(path) {
+    const headers = { Accept: "application/json" };
+    const token = sessionStorage.getItem(tokenKey);
+    if (token) {
+      headers.Authorization = "Bearer " + token;
+    }
+    const response = await fetch(path, { headers });
+    const body = await response.json();
+    if (response.status === 401) {
+      throw new Unauthorized(body.message || response.statusText);
+    }
+    if (!response.ok) {
+      throw new Error(body.message || response.statusText);
+    }
+    return body;
+  }
+
+  function askToken(message) {
+    const input = el("input", { type: "password", placeholder: "Registry token", required: "" });
+    const form = el("form", { class: "token" }, input, el("button", { type: "submit" }, "Sign in"));
+    form.addEventListener("submit", (event) => {
+      event.preventDefault();
+      sessionStorage.setItem(tokenKey, input.value.trim());
+      route();
+    });
+    show(
+      el("h2", {}, "Sign in"),
+      el("p", {}, "This registry requires a token. Run ", el("code", {}, "terraform login " + host),
+        " and paste the token it stored in ", el("code", {}, "~/.terraform.d/credentials.tfrc.json"), "."),
+      el("p", { class: "error" }, message),
+      form);
+  }
+
+  function show(...nodes) {
+    content.replaceChildren(...nodes);
+  }
+
+  function fail(err) {
+    if (err instanceof Unauthorized) {
+      sessionStorage.removeItem(tokenKey);
+      askToken(err.message);
+      return;
+    }
+    show(el("p", { class: "error" }, err.message));
+  }
+
+  function providerLink(p) {
+    return el("a", { href: "#/" + p.namespace + "/" + p.name }, p.id);
+  }
+
+  function providerTable(providers) {
+    if (providers.length === 0) {
+      return el("p", { class: "muted" }, "No providers found.");
+    }
+    const rows = providers.map((p) =>
+      el("tr", {},
+        el("td", {}, el("a", { href: "#/" + p.namespace }, p.namespace)),
+        el("td", {}, providerLink(p)),
+        el("td", {}, p.description || ""),
+        el("td", {}, p.latest_version || "-"),
+        el("td", {}, String(p.platforms))));
+    return el("table", {},
+      el("thead", {}, el("tr", {},
+        el("th", {}, "Namespace"), el("th", {}, "Provider"), el("th", {}, "Description"),
+        el("th", {}, "Latest version"), el("th", {}, "Platforms"))),
+      el("tbody", {}, ...rows));
+  }
+
+  async function listProviders(path, title) {
+    const response = await api(path);
+    show(el("h2", {}, title), providerTable(response.providers));
+  }
+
+  function snippet(namespace, name, version) {
+    return "terraform {\n" +
+      "  required_providers {\n" +
+      "    " + name + " = {\n" +
+      "      source  = \"" + host + "/" + namespace + "/" + name + "\"\n" +
+      "      version = \"" + version + "\"\n" +
+      "    }\n" +
+      "  }\n" +
+      "}\n";
+  }
+
+  async function signingKeys(namespace, name, version) {
+    if (!version) {
+      return "-";
+    }
+    const metadata = await api("/v1/providers/" + namespace + "/" + name + "/" + version.version);
+    return (metadata.signing_keys || []).join(", ") || "-";
+  }
+
+  async function showProvider(namespace, name) {
+    const response = await api("/v1/providers/" + namespace + "/" + name + "/versions");
+    const versions = response.versions;
+    const latest = versions.find((v) => !v.version.includes("-")) || versions[0];
+    const copy = el("button", { type: "button" }, "Copy");
+    const code = snippet(namespace, name, latest ? "~> " + latest.version : ">= 0");
+    copy.addEventListener("click", () => navigator.clipboard.writeText(code));
+    const keys = el("span", { class: "muted" }, "loading");
+
+    const rows = versions.map((v) =>
+      el("tr", {},
+        el("td", {}, v.version),
+        el("td", {}, (v.protocols || []).join(", ")),
+        el("td", {}, v.platforms.map((p) => p.os + "_" + p.arch).join(", "))));
+    show(
+      el("h2", {}, el("a", { href: "#/" + namespace }, namespace), " / " + name),
+      el("p", {}, "Signing key IDs: ", keys),
+      el("h3", {}, "Usage ", copy),
+      el("pre", {}, code),
+      el("h3", {}, "Versions ", el("a", { href: "#/" + namespace + "/" + name + "/platforms" }, "platform matrix")),
+      el("table", {},
+        el("thead", {}, el("tr", {}, el("th", {}, "Version"), el("th", {}, "Protocols"), el("th", {}, "Platforms"))),
+        el("tbody", {}, ...rows)));
+    signingKeys(namespace, name, latest)
+      .then((ids) => keys.replaceChildren(ids))
+      .catch((err) => keys.replaceChildren(err.message));
+  }
+
+  async function showPlatforms(namespace, name) {
+    const matrix = await api("/v1/providers/" + namespace + "/" + name + "/platforms");
+    const rows = matrix.versions.map((v) =>
+      el("tr", { class: v.missing.length > 0 ? "incomplete" : "" },
+        el("td", {}, v.version),
+        ...matrix.platforms.map((p) => el("td", {}, v.missing.includes(p) ? "\u2717" : "\u2713"))));
+    show(
+      el("h2", {}, el("a", { href: "#/" + namespace }, namespace), " / ",
+        el("a", { href: "#/" + namespace + "/" + name }, name), " / platforms"),
+      el("table", { class: "matrix" },
+        el("thead", {}, el("tr", {}, el("th", {}, "Version"), ...matrix.platforms.map((p) => el("th", {}, p)))),
+        el("tbody", {}, ...rows)));
+  }
+
+  function route() {
+    const parts = window.location.hash.replace(/^#\/?/, "").split("/").filter(Boolean);
+    let page;
+    if (parts.length >= 3 && parts[2] === "platforms") {
+      page = showPlatforms(parts[0], parts[1]);
+    } else if (parts.length >= 2) {
+      page = showProvider(parts[0], parts[1]);
+    } else if (parts.length === 1) {
+      page = listProviders("/v1/providers/" + parts[0], parts[0]);
+    } else {
+      page = listProviders("/v1/providers", "Providers");
+    }
+    page.catch(fail);
+  }
+
+  document.getElementById("search").addEventListener("submit", (event) => {
+    event.preventDefault();
+    const query = document.getElementById("query").value.trim();
+    if (query === "") {
+      window.location.hash = "#/";
+      return;
+    }
+    listProviders("/v1/providers?q=" + encodeURIComponent(query), "Results for " + query)
+      .catch(() => { window.location.hash = "#/" + query; });
+  });
+  window.addEventListener("hashchange", route);
+  route();
+})();
@@ -0,0 +1,168 @@
+/*
+Copyright © 2020 Andy Lo-A-Foe
+
+Permission is hereby granted, free of charge, to any person obtaining a copy
+of this software and associated documentation files (the "Software"), to deal
+in the Software without restriction, including without limitation the rights
+to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+copies of the Software, and to permit persons to whom the Software is
+furnished to do so, subject to the following conditions:
+
+The above copyright notice and this permission notice shall be included in
+all copies or substantial portions of the Software.
+
+THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+THE SOFTWARE.
+*/
+package upstream
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"strings"
+	"sync"
+	"time"
+
+	"terraform-registry/internal/cache"
+	"terraform-registry/internal/download"
+
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/trace"
+)
+
+var tracer = otel.Tracer("terraform-registry/internal/upstream")
+
+// Response is a response of the upstream registry
+type Response struct {
+	Status int
+	Body   []byte
+}
+
+// Registry forwards provider requests to another registry, found through its
+// service discovery document
+type Registry struct {
+	base   *url.URL
+	client *http.Client
+	cache  *cache.Cache[*Response]
+
+	mu        sync.Mutex
+	providers *url.URL
+}
+
+// New creates a Registry for host, which is either a hostname such as
+// registry.terraform.io or a URL, requested with client. Successful responses are
+// cached for cacheTTL.
+func New(host string, cacheTTL time.Duration, client *http.Client) (*Registry, error) {
+	if !strings.Contains(host, "://") {
+		host = "https://" + host
+	}
+	base, err := url.Parse(host)
+	if err != nil {
+		return nil, fmt.Errorf("invalid upstream registry: %w", err)
+	}
+	return &Registry{
+		base:   base,
+		client: client,
+		cache:  cache.New[*Response](cacheTTL),
+	}, nil
+}
+
+// Host returns the host of the upstream registry
+func (r *Registry) Host() string {
+	return r.base.Host
+}
+
+// CacheStats returns the hits and misses of the response cache
+func (r *Registry) CacheStats() (hits, misses uint64) {
+	return r.cache.Stats()
+}
+
+// Get performs a request against the providers.v1 service of the upstream registry,
+// path being relative to it, e.g. hashicorp/aws/versions
+func (r *Registry) Get(ctx context.Context, path string) (*Response, error) {
+	ctx, span := tracer.Start(ctx, "upstream.Get", trace.WithAttributes(attribute.String("path", path)))
+	defer span.End()
+
+	if cached, ok := r.cache.Get(path); ok {
+		span.SetAttributes(attribute.Bool("cache.hit", true))
+		return cached, nil
+	}
+	providers, err := r.discover(ctx)
+	if err != nil {
+		span.SetStatus(codes.Error, err.Error())
+		return nil, err
+	}
+	target := providers.ResolveReference(&url.URL{Path: path})
+
+	resp, err := r.get(ctx, target.String())
+	if err != nil {
+		span.SetStatus(codes.Error, err.Error())
+		return nil, err
+	}
+	defer func() { _ = resp.Body.Close() }()
+
+	body, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return nil, err
+	}
+	response := &Response{Status: resp.StatusCode, Body: body}
+	if resp.StatusCode == http.StatusOK {
+		r.cache.Set(path, response)
+	}
+	return response, nil
+}
+
+// discover looks up the providers.v1 service URL, remembering it once found
+func (r *Registry) discover(ctx context.Context) (*url.URL, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if r.providers != nil {
+		return r.providers, nil
+	}
+
+	resp, err := r.get(ctx, r.base.ResolveReference(&url.URL{Path: "/.well-known/terraform.json"}).String())
+	if err != nil {
+		return nil, fmt.Errorf("upstream discovery: %w", err)
+	}
+	defer func() { _ = resp.Body.Close() }()
+
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("upstream discovery: unexpected status %d", resp.StatusCode)
+	}
+	var services map[string]interface{}
+	if err := json.NewDecoder(resp.Body).Decode(&services); err != nil {
+		return nil, fmt.Errorf("upstream discovery: %w", err)
+	}
+	service, ok := services["providers.v1"].(string)
+	if !ok {
+		return nil, fmt.Errorf("upstream discovery: %s does not offer providers.v1", r.base.Host)
+	}
+	if !strings.HasSuffix(service, "/") {
+		service += "/"
+	}
+	providers, err := r.base.Parse(service)
+	if err != nil {
+		return nil, fmt.Errorf("upstream discovery: %w", err)
+	}
+	r.providers = providers
+	return providers, nil
+}
+
+func (r *Registry) get(ctx context.Context, target string) (*http.Response, error) {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, target, nil)
+	if err != nil {
+		return nil, err
+	}
+	return download.Do(r.client, req)
+}
@@ -0,0 +1,104 @@
+/*
+Copyright © 2020 Andy Lo-A-Foe
+
+Permission is hereby granted, free of charge, to any person obtaining a copy
+of this software and associated documentation files (the "Software"), to deal
+in the Software without restriction, including without limitation the rights
+to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+copies of the Software, and to permit persons to whom the Software is
+furnished to do so, subject to the following conditions:
+
+The above copyright notice and this permission notice shall be included in
+all copies or substantial portions of the Software.
+
+THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+THE SOFTWARE.
+*/
+package upstream
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+)
+
+func newTestUpstream(t *testing.T, hits *int) *httptest.Server {
+	mux := http.NewServeMux()
+	mux.HandleFunc("/.well-known/terraform.json", func(w http.ResponseWriter, r *http.Request) {
+		_, _ = w.Write([]byte(`{"providers.v1":"/registry/v1/providers"}`))
+	})
+	mux.HandleFunc("/registry/v1/providers/hashicorp/aws/versions", func(w http.ResponseWriter, r *http.Request) {
+		*hits++
+		_, _ = w.Write([]byte(`{"id":"hashicorp/aws","versions":[{"version":"5.0.0"}]}`))
+	})
+	server := httptest.NewServer(mux)
+	t.Cleanup(server.Close)
+	return server
+}
+
+func TestGet(t *testing.T) {
+	hits := 0
+	server := newTestUpstream(t, &hits)
+
+	registry, err := New(server.URL, time.Minute, nil)
+	if err != nil {
+		t.Fatalf("New() error = %v", err)
+	}
+
+	tests := []struct {
+		name       string
+		path       string
+		wantStatus int
+	}{
+		{name: "Versions", path: "hashicorp/aws/versions", wantStatus: http.StatusOK},
+		{name: "Cached versions", path: "hashicorp/aws/versions", wantStatus: http.StatusOK},
+		{name: "Unknown provider", path: "hashicorp/unknown/versions", wantStatus: http.StatusNotFound},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			resp, err := registry.Get(context.Background(), tt.path)
+			if err != nil {
+				t.Fatalf("Get() error = %v", err)
+			}
+			if resp.Status != tt.wantStatus {
+				t.Errorf("Get() status = %d, want %d", resp.Status, tt.wantStatus)
+			}
+		})
+	}
+	if hits != 1 {
+		t.Errorf("Expected 1 upstream request, got %d", hits)
+	}
+}
+
+func TestGetWithoutProviders(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		_, _ = w.Write([]byte(`{"modules.v1":"/v1/modules/"}`))
+	}))
+	defer server.Close()
+
+	registry, _ := New(server.URL, 0, nil)
+	if _, err := registry.Get(context.Background(), "hashicorp/aws/versions"); err == nil {
+		t.Error("Get() expected error for registry without providers.v1, got nil")
+	}
+}
+
+func TestNewHost(t *testing.T) {
+	registry, err := New("registry.terraform.io", 0, nil)
+	if err != nil {
+		t.Fatalf("New() error = %v", err)
+	}
+	if registry.Host() != "registry.terraform.io" {
+		t.Errorf("Host() = %s, want registry.terraform.io", registry.Host())
+	}
+	if registry.base.Scheme != "https" {
+		t.Errorf("Expected https scheme, got %s", registry.base.Scheme)
+	}
+}
@@ -0,0 +1,244 @@
+/*
+Copyright © 2020 Andy Lo-A-Foe
+
+Permission is hereby granted, free of charge, to any person obtaining a copy
+of this software and associated documentation files (the "Software"), to deal
+in the Software without restriction, including without limitation the rights
+to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+copies of the Software, and to permit persons to whom the Software is
+furnished to do so, subject to the following conditions:
+
+The above copyright notice and this permission notice shall be included in
+all copies or substantial portions of the Software.
+
+THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+THE SOFTWARE.
+*/
+package verify
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"sync"
+	"sync/atomic"
+
+	"terraform-registry/internal/download"
+)
+
+// Status is the verification state of a platform zip
+type Status int
+
+const (
+	// Unknown means the zip was not verified yet
+	Unknown Status = iota
+	// Verified means the zip matches its SHA256SUMS entry
+	Verified
+	// Mismatch means the zip does not match its SHA256SUMS entry
+	Mismatch
+)
+
+const (
+	// OnRequest verifies a zip before answering its first download request
+	OnRequest = "request"
+	// Background answers download requests right away and verifies in the background
+	Background = "background"
+
+	queueSize = 100
+)
+
+// Platform identifies the zip of a provider version for one os and arch
+type Platform struct {
+	Namespace string
+	Type      string
+	Version   string
+	Os        string
+	Arch      string
+}
+
+func (p Platform) String() string {
+	return fmt.Sprintf("%s/%s %s %s_%s", p.Namespace, p.Type, p.Version, p.Os, p.Arch)
+}
+
+type job struct {
+	ctx      context.Context
+	platform Platform
+	url      string
+	shasum   string
+}
+
+// Verifier checks provider zips against their SHA256SUMS entries and remembers
+// which platforms turned out to mismatch
+type Verifier struct {
+	mode     string
+	client   *http.Client
+	jobs     chan job
+	wg       sync.WaitGroup
+	stopping atomic.Bool
+	// queue guards sending on jobs against closing it
+	queue  sync.RWMutex
+	closed bool
+
+	mu       sync.Mutex
+	results  map[Platform]Status
+	inflight map[Platform]chan struct{}
+}
+
+// New creates a Verifier in the given mode, downloading zips with client. In Background
+// mode the given number of workers process the verifications, until Close is called.
+func New(mode string, workers int, client *http.Client) (*Verifier, error) {
+	if mode != OnRequest && mode != Background {
+		return nil, fmt.Errorf("invalid verification mode %q", mode)
+	}
+	v := &Verifier{
+		mode:     mode,
+		client:   client,
+		results:  make(map[Platform]Status),
+		inflight: make(map[Platform]chan struct{}),
+	}
+	if mode == Background {
+		v.jobs = make(chan job, queueSize)
+		for i := 0; i < workers; i++ {
+			v.wg.Add(1)
+			go func() {
+				defer v.wg.Done()
+				for j := range v.jobs {
+					if v.stopping.Load() {
+						continue
+					}
+					_, _ = v.Verify(j.ctx, j.platform, j.url, j.shasum)
+				}
+			}()
+		}
+	}
+	return v, nil
+}
+
+// Close stops the background workers, waiting for running verifications
+func (v *Verifier) Close() {
+	if v.jobs == nil {
+		return
+	}
+	v.queue.Lock()
+	if !v.closed {
+		v.closed = true
+		close(v.jobs)
+	}
+	v.queue.Unlock()
+	v.wg.Wait()
+}
+
+// Shutdown stops the background workers, skipping queued verifications and waiting for
+// running ones until ctx is done
+func (v *Verifier) Shutdown(ctx context.Context) error {
+	if v == nil {
+		return nil
+	}
+	v.stopping.Store(true)
+	done := make(chan struct{})
+	go func() {
+		v.Close()
+		close(done)
+	}()
+	select {
+	case <-done:
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
+// Status returns the recorded verification status of a platform
+func (v *Verifier) Status(p Platform) Status {
+	if v == nil {
+		return Unknown
+	}
+	v.mu.Lock()
+	defer v.mu.Unlock()
+	return v.results[p]
+}
+
+// Mark records the verification status of a platform found by other means
+func (v *Verifier) Mark(p Platform, status Status) {
+	if v == nil {
+		return
+	}
+	v.mu.Lock()
+	defer v.mu.Unlock()
+	v.results[p] = status
+}
+
+// Check verifies a platform according to the mode of the Verifier. OnRequest verifies
+// unknown platforms right away, Background queues them and returns the current status.
+func (v *Verifier) Check(ctx context.Context, p Platform, url, shasum string) (Status, error) {
+	if status := v.Status(p); status != Unknown {
+		return status, nil
+	}
+	if v.mode == OnRequest {
+		return v.Verify(ctx, p, url, shasum)
+	}
+	v.queue.RLock()
+	defer v.queue.RUnlock()
+	if v.closed {
+		return Unknown, nil
+	}
+	select {
+	case v.jobs <- job{ctx: context.WithoutCancel(ctx), platform: p, url: url, shasum: shasum}:
+	default:
+		// The queue is full, the platform is verified on a later request
+	}
+	return Unknown, nil
+}
+
+// Verify streams the zip at url and compares its SHA256 sum to shasum. Outcomes are
+// recorded, failed downloads are not. Concurrent verifications of a platform are shared.
+func (v *Verifier) Verify(ctx context.Context, p Platform, url, shasum string) (Status, error) {
+	v.mu.Lock()
+	if status := v.results[p]; status != Unknown {
+		v.mu.Unlock()
+		return status, nil
+	}
+	if done, ok := v.inflight[p]; ok {
+		v.mu.Unlock()
+		<-done
+		return v.Status(p), nil
+	}
+	done := make(chan struct{})
+	v.inflight[p] = done
+	v.mu.Unlock()
+
+	status, err := verify(ctx, v.client, url, shasum)
+
+	v.mu.Lock()
+	if err == nil {
+		v.results[p] = status
+	}
+	delete(v.inflight, p)
+	v.mu.Unlock()
+	close(done)
+	return status, err
+}
+
+func verify(ctx context.Context, client *http.Client, url, shasum string) (Status, error) {
+	body, err := download.Open(ctx, client, url)
+	if err != nil {
+		return Unknown, err
+	}
+	defer func() { _ = body.Close() }()
+
+	_, err = io.Copy(io.Discard, download.VerifyingReader(body, shasum))
+	switch {
+	case errors.Is(err, download.ErrChecksumMismatch):
+		return Mismatch, nil
+	case err != nil:
+		return Unknown, err
+	}
+	return Verified, nil
+}
@@ -0,0 +1,204 @@
+/*
+Copyright © 2020 Andy Lo-A-Foe
+
+Permission is hereby granted, free of charge, to any person obtaining a copy
+of this software and associated documentation files (the "Software"), to deal
+in the Software without restriction, including without limitation the rights
+to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+copies of the Software, and to permit persons to whom the Software is
+furnished to do so, subject to the following conditions:
+
+The above copyright notice and this permission notice shall be included in
+all copies or substantial portions of the Software.
+
+THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+THE SOFTWARE.
+*/
+package verify
+
+import (
+	"context"
+	"crypto/sha256"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+var testPlatform = Platform{
+	Namespace: "philips-labs",
+	Type:      "hsdp",
+	Version:   "1.0.0",
+	Os:        "linux",
+	Arch:      "amd64",
+}
+
+func newTestServer(t *testing.T, content string, requests *int32) *httptest.Server {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		atomic.AddInt32(requests, 1)
+		if r.URL.Path == "/missing" {
+			w.WriteHeader(http.StatusNotFound)
+			return
+		}
+		_, _ = w.Write([]byte(content))
+	}))
+	t.Cleanup(server.Close)
+	return server
+}
+
+func TestVerify(t *testing.T) {
+	content := "zip content"
+	var requests int32
+	server := newTestServer(t, content, &requests)
+
+	tests := []struct {
+		name       string
+		path       string
+		shasum     string
+		wantStatus Status
+		wantErr    bool
+	}{
+		{name: "Matching", shasum: fmt.Sprintf("%x", sha256.Sum256([]byte(content))), wantStatus: Verified},
+		{name: "Mismatching", shasum: "abc123def456", wantStatus: Mismatch},
+		{name: "Download failure", path: "/missing", shasum: "abc123def456", wantStatus: Unknown, wantErr: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			v, _ := New(OnRequest, 0, nil)
+			status, err := v.Verify(context.Background(), testPlatform, server.URL+tt.path, tt.shasum)
+			if (err != nil) != tt.wantErr {
+				t.Errorf("Verify() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if status != tt.wantStatus {
+				t.Errorf("Verify() status = %v, want %v", status, tt.wantStatus)
+			}
+			if v.Status(testPlatform) != tt.wantStatus {
+				t.Errorf("Status() = %v, want %v", v.Status(testPlatform), tt.wantStatus)
+			}
+		})
+	}
+}
+
+func TestCheckOnRequest(t *testing.T) {
+	var requests int32
+	server := newTestServer(t, "zip content", &requests)
+
+	v, _ := New(OnRequest, 0, nil)
+	for i := 0; i < 3; i++ {
+		status, err := v.Check(context.Background(), testPlatform, server.URL, "abc123def456")
+		if err != nil || status != Mismatch {
+			t.Errorf("Check() = %v, %v, want Mismatch", status, err)
+		}
+	}
+	if requests != 1 {
+		t.Errorf("Expected 1 download, got %d", requests)
+	}
+}
+
+func TestCheckBackground(t *testing.T) {
+	var requests int32
+	server := newTestServer(t, "zip content", &requests)
+
+	v, err := New(Background, 1, nil)
+	if err != nil {
+		t.Fatalf("New() error = %v", err)
+	}
+	status, err := v.Check(context.Background(), testPlatform, server.URL, "abc123def456")
+	if err != nil || status != Unknown {
+		t.Errorf("Check() = %v, %v, want Unknown", status, err)
+	}
+
+	deadline := time.Now().Add(5 * time.Second)
+	for v.Status(testPlatform) == Unknown && time.Now().Before(deadline) {
+		time.Sleep(5 * time.Millisecond)
+	}
+	v.Close()
+	if v.Status(testPlatform) != Mismatch {
+		t.Errorf("Status() = %v, want Mismatch", v.Status(testPlatform))
+	}
+}
+
+func TestShutdown(t *testing.T) {
+	var requests int32
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		atomic.AddInt32(&requests, 1)
+		time.Sleep(20 * time.Millisecond)
+		_, _ = w.Write([]byte("zip content"))
+	}))
+	defer server.Close()
+
+	v, err := New(Background, 1, nil)
+	if err != nil {
+		t.Fatalf("New() error = %v", err)
+	}
+	for i := 0; i < 5; i++ {
+		platform := testPlatform
+		platform.Version = fmt.Sprintf("1.0.%d", i)
+		_, _ = v.Check(context.Background(), platform, server.URL, "abc123def456")
+	}
+	time.Sleep(5 * time.Millisecond)
+	if err := v.Shutdown(context.Background()); err != nil {
+		t.Errorf("Shutdown() error = %v", err)
+	}
+	if n := atomic.LoadInt32(&requests); n > 1 {
+		t.Errorf("Expected queued verifications to be skipped, got %d downloads", n)
+	}
+	if err := (*Verifier)(nil).Shutdown(context.Background()); err != nil {
+		t.Errorf("Shutdown() of nil Verifier error = %v", err)
+	}
+}
+
+func TestCheckDuringShutdown(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		_, _ = w.Write([]byte("zip content"))
+	}))
+	defer server.Close()
+
+	v, err := New(Background, 1, nil)
+	if err != nil {
+		t.Fatalf("New() error = %v", err)
+	}
+	var wg sync.WaitGroup
+	for i := 0; i < 4; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for j := 0; j < 200; j++ {
+				platform := testPlatform
+				platform.Version = fmt.Sprintf("1.%d.%d", i, j)
+				_, _ = v.Check(context.Background(), platform, server.URL, "abc123def456")
+			}
+		}()
+	}
+	if err := v.Shutdown(context.Background()); err != nil {
+		t.Errorf("Shutdown() error = %v", err)
+	}
+	wg.Wait()
+	if status, err := v.Check(context.Background(), testPlatform, server.URL, "abc123def456"); status != Unknown || err != nil {
+		t.Errorf("Check() after Shutdown = %v, %v, want Unknown", status, err)
+	}
+	v.Close()
+}
+
+func TestNewInvalidMode(t *testing.T) {
+	if _, err := New("sometimes", 1, nil); err == nil {
+		t.Error("New() expected error for invalid mode, got nil")
+	}
+}
+
+func TestNilVerifier(t *testing.T) {
+	var v *Verifier
+	v.Mark(testPlatform, Mismatch)
+	if v.Status(testPlatform) != Unknown {
+		t.Error("nil Verifier should report Unknown")
+	}
+}
@@ -22,38 +22,260 @@ THE SOFTWARE.
 package main
 
 import (
-	"fmt"
+	"context"
+	"errors"
+	"flag"
+	"log/slog"
+	"net/http"
 	"os"
+	"os/signal"
+	"syscall"
 
+	"terraform-registry/internal/auth"
+	"terraform-registry/internal/certs"
 	"terraform-registry/internal/client"
+	"terraform-registry/internal/config"
+	"terraform-registry/internal/docs"
+	"terraform-registry/internal/download"
 	"terraform-registry/internal/handler"
+	"terraform-registry/internal/hashes"
+	"terraform-registry/internal/health"
+	"terraform-registry/internal/logging"
+	"terraform-registry/internal/metrics"
+	"terraform-registry/internal/mirror"
+	"terraform-registry/internal/models"
+	"terraform-registry/internal/storage"
+	"terraform-registry/internal/tracing"
+	"terraform-registry/internal/ui"
+	"terraform-registry/internal/upstream"
+	"terraform-registry/internal/verify"
 
 	"github.com/labstack/echo/v4"
 	"github.com/labstack/echo/v4/middleware"
+	"go.opentelemetry.io/contrib/instrumentation/github.com/labstack/echo/otelecho"
 )
 
 func main() {
+	configFile := flag.String("config", os.Getenv("CONFIG_FILE"), "path of the YAML configuration file")
+	flag.Parse()
+
+	cfg, err := config.Load(*configFile)
+	if err != nil {
+		fatal("loading configuration", err)
+	}
+	logger, err := logging.New(os.Stdout, cfg.Log)
+	if err != nil {
+		fatal("configuring logging", err)
+	}
+	slog.SetDefault(logger)
+
 	e := echo.New()
-	e.Use(middleware.Logger())
+	e.HideBanner = true
+	e.HidePort = true
+	e.Use(middleware.RequestID())
+	e.Use(logging.Middleware(logger))
+
+	m := metrics.New()
+	e.Use(m.Middleware())
+
+	shutdownTracing, err := tracing.Setup(context.Background())
+	if err != nil {
+		fatal("setting up tracing", err)
+	}
+	e.Use(otelecho.Middleware(tracing.ServiceName))
 
-	client, err := client.NewClient()
+	if cfg.Server.RequestTimeout > 0 {
+		e.Use(middleware.ContextTimeoutWithConfig(middleware.ContextTimeoutConfig{
+			Timeout:      cfg.Server.RequestTimeout,
+			ErrorHandler: handler.TimeoutErrorHandler,
+		}))
+	}
+	httpClient := &http.Client{Transport: m.Transport(metrics.Download, download.NewTransport(cfg.Server.UpstreamTimeout))}
+
+	client, err := client.NewClient(
+		client.WithTransport(m.Transport(metrics.GitHub, download.NewTransport(cfg.Server.UpstreamTimeout))),
+		client.WithToken(cfg.GitHub.Token),
+		client.WithEnterprise(cfg.GitHub.EnterpriseURL, cfg.GitHub.EnterpriseUploadsURL),
+		client.WithReleaseCacheTTL(cfg.Cache.ReleasesTTL),
+		client.WithNamespaces(cfg.Namespaces),
+	)
+	if err != nil {
+		fatal("creating GitHub client", err)
+	}
+
+	var loginService *models.LoginV1
+	var authenticated []echo.MiddlewareFunc
+
+	if cfg.OIDC.IssuerURL != "" {
+		login, err := auth.NewLogin(cfg.OIDC)
+		if err != nil {
+			fatal("setting up login", err)
+		}
+		loginService = login.Service()
+		e.GET(auth.AuthorizationPath, login.AuthorizationHandler())
+		e.GET(auth.CallbackPath, login.CallbackHandler())
+		e.POST(auth.TokenPath, login.TokenHandler())
+		authenticated = append(authenticated, auth.Middleware(login.Issuer))
+	}
+
+	m.RegisterCache("releases", client.CacheStats)
+	opts := handler.Options{
+		Hasher:     hashes.New(),
+		Docs:       docs.New(client.Github),
+		Metrics:    m,
+		Owners:     cfg.GitHub.Owners,
+		HTTPClient: httpClient,
+	}
+	opts.Policy, err = cfg.Policy.Engine()
 	if err != nil {
-		e.Logger.Error(err)
-		os.Exit(1)
+		fatal("loading policy", err)
 	}
 
-	e.GET("/.well-known/terraform.json", handler.ServiceDiscoveryHandler())
-	e.GET("/v1/providers/:namespace/:type/*", handler.ProviderHandler(client))
+	if host := cfg.Upstream.Registry; host != "" {
+		opts.Upstream, err = upstream.New(host, cfg.Upstream.CacheTTL, httpClient)
+		if err != nil {
+			fatal("setting up upstream registry", err)
+		}
+		m.RegisterCache("upstream", opts.Upstream.CacheStats)
+	}
 
-	port := os.Getenv("PORT")
+	if dir := cfg.Mirror.Dir; dir != "" {
+		store, err := storage.NewLocal(dir)
+		if err != nil {
+			fatal("opening mirror storage", err)
+		}
+		opts.Mirror = mirror.New(store, []byte(cfg.Mirror.URLSecret), httpClient)
+		e.GET(mirror.PathPrefix+":namespace/:type/:version/:filename", handler.MirrorHandler(client, opts))
+	}
 
-	if port == "" {
-		port = "8080"
+	if mode := cfg.Verify.Mode; mode != "" {
+		opts.Verifier, err = verify.New(mode, cfg.Verify.Workers, httpClient)
+		if err != nil {
+			fatal("setting up verification", err)
+		}
 	}
 
-	err = e.Start(fmt.Sprintf(":%s", port))
+	e.GET("/.well-known/terraform.json", handler.ServiceDiscoveryHandler(loginService))
+	registry := newReloader(*configFile, cfg, client, opts)
+	e.GET("/v1/providers", registry.providers, authenticated...)
+	e.GET("/v1/providers/:namespace", registry.providers, authenticated...)
+	e.GET("/v1/providers/:namespace/:type/*", registry.provider, authenticated...)
+	e.GET(handler.NetworkMirrorPath+":hostname/:namespace/:type/:file", registry.networkMirror, authenticated...)
+	e.GET(handler.DocsPath, registry.docs, authenticated...)
+	e.GET(handler.DocsPath+"/:category/:slug", registry.docs, authenticated...)
+	e.POST(handler.LockPath, registry.lock, authenticated...)
+	e.GET(ui.Path+"*", ui.Handler())
+	// The downloads metric names providers the policy may hide, so it is only served to
+	// authenticated callers or on a separate, internal listener
+	var metricsServer *echo.Echo
+	if cfg.Server.MetricsAddress != "" {
+		metricsServer = echo.New()
+		metricsServer.HideBanner = true
+		metricsServer.HidePort = true
+		metricsServer.GET(metrics.Path, m.Handler())
+	} else {
+		e.GET(metrics.Path, m.Handler(), authenticated...)
+	}
+	e.GET("/", func(c echo.Context) error {
+		return c.Redirect(http.StatusFound, ui.Path)
+	})
+	checker := health.New(client)
+	e.GET(health.LivenessPath, checker.LivenessHandler())
+	e.GET(health.ReadinessPath, checker.ReadinessHandler())
+
+	tlsConfig, err := certs.NewConfig(cfg.Server.TLS)
 	if err != nil {
-		e.Logger.Error(err)
-		os.Exit(1)
+		fatal("setting up TLS", err)
+	}
+
+	logger.Info("listening", "address", cfg.Server.Address, "tls", tlsConfig != nil)
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
+	go func() {
+		var err error
+		if tlsConfig != nil {
+			e.TLSServer.Addr = cfg.Server.Address
+			e.TLSServer.TLSConfig = tlsConfig
+			err = e.StartServer(e.TLSServer)
+		} else {
+			err = e.Start(cfg.Server.Address)
+		}
+		if err != nil && !errors.Is(err, http.ErrServerClosed) {
+			fatal("serving", err)
+		}
+	}()
+	if metricsServer != nil {
+		logger.Info("serving metrics", "address", cfg.Server.MetricsAddress)
+		go func() {
+			if err := metricsServer.Start(cfg.Server.MetricsAddress); err != nil && !errors.Is(err, http.ErrServerClosed) {
+				fatal("serving metrics", err)
+			}
+		}()
+	}
+
+	var watcher *config.Watcher
+	reload := func(reason string) {
+		restart, err := registry.Reload()
+		m.Reload(err)
+		if err != nil {
+			logger.Error("reloading configuration failed, keeping the running configuration", "trigger", reason, "error", err)
+			return
+		}
+		logger.Info("reloaded configuration", "trigger", reason)
+		// The policy file may have moved
+		if err := watcher.SetFiles(registry.Files()); err != nil {
+			logger.Error("watching configuration", "error", err)
+		}
+		if restart {
+			logger.Warn("configuration changes besides namespaces, owners and policy are applied on restart")
+		}
 	}
+	if files := registry.Files(); len(files) > 0 {
+		if watcher, err = config.NewWatcher(files); err != nil {
+			logger.Error("watching configuration", "error", err)
+		} else {
+			go watcher.Run(ctx, func() { reload("file change") })
+		}
+	}
+	hangup := make(chan os.Signal, 1)
+	signal.Notify(hangup, syscall.SIGHUP)
+	defer signal.Stop(hangup)
+
+	for running := true; running; {
+		select {
+		case <-hangup:
+			reload("SIGHUP")
+		case <-ctx.Done():
+			running = false
+		}
+	}
+	stop()
+
+	// Drain in-flight requests first, they may still queue verifications and mirror fetches
+	logger.Info("shutting down", "timeout", cfg.Server.ShutdownTimeout.String())
+	drain, cancel := context.WithTimeout(context.Background(), cfg.Server.ShutdownTimeout)
+	defer cancel()
+	if err := e.Shutdown(drain); err != nil {
+		logger.Error("draining requests", "error", err)
+	}
+	if metricsServer != nil {
+		if err := metricsServer.Shutdown(drain); err != nil {
+			logger.Error("stopping metrics listener", "error", err)
+		}
+	}
+	if err := opts.Mirror.Wait(drain); err != nil {
+		logger.Error("waiting for mirror fetches", "error", err)
+	}
+	if err := opts.Verifier.Shutdown(drain); err != nil {
+		logger.Error("stopping verifier", "error", err)
+	}
+	if err := shutdownTracing(drain); err != nil {
+		logger.Error("flushing traces", "error", err)
+	}
+}
+
+// fatal logs err and exits, for errors the registry cannot start with
+func fatal(msg string, err error) {
+	slog.Error(msg, "error", err)
+	os.Exit(1)
 }
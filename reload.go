@@ -0,0 +1,119 @@
+/*
+Copyright © 2020 Andy Lo-A-Foe
+
+Permission is hereby granted, free of charge, to any person obtaining a copy
+of this software and associated documentation files (the "Software"), to deal
+in the Software without restriction, including without limitation the rights
+to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+copies of the Software, and to permit persons to whom the Software is
+furnished to do so, subject to the following conditions:
+
+The above copyright notice and this permission notice shall be included in
+all copies or substantial portions of the Software.
+
+THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+THE SOFTWARE.
+*/
+package main
+
+import (
+	"sync"
+	"sync/atomic"
+
+	"terraform-registry/internal/client"
+	"terraform-registry/internal/config"
+	"terraform-registry/internal/handler"
+
+	"github.com/labstack/echo/v4"
+)
+
+// registryHandlers are the handlers built from settings which can be reloaded
+type registryHandlers struct {
+	providers     echo.HandlerFunc
+	provider      echo.HandlerFunc
+	networkMirror echo.HandlerFunc
+	docs          echo.HandlerFunc
+	lock          echo.HandlerFunc
+}
+
+func newRegistryHandlers(client *client.Client, opts handler.Options) *registryHandlers {
+	return &registryHandlers{
+		providers:     handler.ProvidersHandler(client, opts),
+		provider:      handler.ProviderHandler(client, opts),
+		networkMirror: handler.NetworkMirrorHandler(client, opts),
+		docs:          handler.DocsHandler(client, opts),
+		lock:          handler.LockHandler(client, opts),
+	}
+}
+
+// reloader serves the registry routes and swaps in new handlers when the configuration
+// is reloaded. Requests in flight finish with the handlers they started with.
+type reloader struct {
+	filename string
+	client   *client.Client
+	opts     handler.Options
+	handlers atomic.Pointer[registryHandlers]
+
+	mu      sync.Mutex
+	current *config.Config
+}
+
+func newReloader(filename string, cfg *config.Config, client *client.Client, opts handler.Options) *reloader {
+	r := &reloader{
+		filename: filename,
+		client:   client,
+		opts:     opts,
+		current:  cfg,
+	}
+	r.handlers.Store(newRegistryHandlers(client, opts))
+	return r
+}
+
+// Reload loads and validates the configuration again and applies the namespaces, GitHub
+// owners and policy. The running configuration is kept when loading fails. It reports
+// whether the new configuration also changed settings which need a restart.
+func (r *reloader) Reload() (restart bool, err error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	cfg, err := config.Load(r.filename)
+	if err != nil {
+		return false, err
+	}
+	opts := r.opts
+	opts.Owners = cfg.GitHub.Owners
+	if opts.Policy, err = cfg.Policy.Engine(); err != nil {
+		return false, err
+	}
+
+	r.client.SetNamespaces(cfg.Namespaces)
+	r.handlers.Store(newRegistryHandlers(r.client, opts))
+	restart = r.current.RequiresRestart(cfg)
+	r.current = cfg
+	return restart, nil
+}
+
+// Files returns the files the configuration is read from
+func (r *reloader) Files() []string {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	var files []string
+	if r.filename != "" {
+		files = append(files, r.filename)
+	}
+	if r.current.Policy.File != "" {
+		files = append(files, r.current.Policy.File)
+	}
+	return files
+}
+
+func (r *reloader) providers(c echo.Context) error     { return r.handlers.Load().providers(c) }
+func (r *reloader) provider(c echo.Context) error      { return r.handlers.Load().provider(c) }
+func (r *reloader) networkMirror(c echo.Context) error { return r.handlers.Load().networkMirror(c) }
+func (r *reloader) docs(c echo.Context) error          { return r.handlers.Load().docs(c) }
+func (r *reloader) lock(c echo.Context) error          { return r.handlers.Load().lock(c) }